@@ -0,0 +1,171 @@
+package goksei
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultMaxConcurrency = 4
+
+// Account contains the credentials of a KSEI login managed by an AccountManager.
+type Account struct {
+	Username      string
+	Password      string
+	PlainPassword bool
+	Credentials   CredentialProvider // if set, Password is ignored
+}
+
+// AccountManagerOpts contains configuration options for creating a new AccountManager.
+type AccountManagerOpts struct {
+	// ClientOpts is the template used for the client of every account. Its AuthStore,
+	// HTTP client and rate limiter are shared by all accounts; the credential fields are
+	// taken from each Account instead.
+	ClientOpts ClientOpts
+
+	// MaxConcurrency limits how many accounts are queried at once (default: 4).
+	MaxConcurrency int
+}
+
+// AccountResult holds the outcome of a request made for one account.
+type AccountResult[T any] struct {
+	Data T
+	Err  error
+}
+
+// AccountManager manages clients for multiple KSEI accounts sharing one AuthStore
+// and HTTP transport, and queries them concurrently with bounded parallelism.
+// It is safe for concurrent use.
+type AccountManager struct {
+	opts AccountManagerOpts
+
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewAccountManager creates a new AccountManager with the provided options.
+func NewAccountManager(opts AccountManagerOpts) *AccountManager {
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = defaultMaxConcurrency
+	}
+
+	// share one transport between accounts so connections are pooled
+	if opts.ClientOpts.HTTPClient == nil {
+		opts.ClientOpts.HTTPClient = &http.Client{}
+	}
+
+	return &AccountManager{
+		opts:    opts,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Add creates a client for account, replacing any previous account with the same username.
+// The client is returned for further configuration, e.g. SetBaseURL.
+func (m *AccountManager) Add(account Account) *Client {
+	clientOpts := m.opts.ClientOpts
+	clientOpts.Username = account.Username
+	clientOpts.Password = account.Password
+	clientOpts.PlainPassword = account.PlainPassword
+	clientOpts.Credentials = account.Credentials
+
+	client := NewClient(clientOpts)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clients[account.Username] = client
+
+	return client
+}
+
+// Remove removes the account with the given username, if any.
+func (m *AccountManager) Remove(username string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.clients, username)
+}
+
+// Client returns the client of the account with the given username.
+func (m *AccountManager) Client(username string) (client *Client, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok = m.clients[username]
+
+	return client, ok
+}
+
+// Usernames returns the usernames of all managed accounts, sorted.
+func (m *AccountManager) Usernames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usernames := make([]string, 0, len(m.clients))
+	for username := range m.clients {
+		usernames = append(usernames, username)
+	}
+
+	slices.Sort(usernames)
+
+	return usernames
+}
+
+// GetPortfolioSummary calls GetPortfolioSummaryContext for all accounts concurrently.
+// Results and errors are keyed by username; a failing account does not affect the others.
+func (m *AccountManager) GetPortfolioSummary(ctx context.Context) map[string]AccountResult[*PortfolioSummaryResponse] {
+	return forEachAccount(ctx, m, func(ctx context.Context, client *Client) (*PortfolioSummaryResponse, error) {
+		return client.GetPortfolioSummaryContext(ctx)
+	})
+}
+
+// GetCashBalances calls GetCashBalancesContext for all accounts concurrently.
+// Results and errors are keyed by username; a failing account does not affect the others.
+func (m *AccountManager) GetCashBalances(ctx context.Context) map[string]AccountResult[*CashBalanceResponse] {
+	return forEachAccount(ctx, m, func(ctx context.Context, client *Client) (*CashBalanceResponse, error) {
+		return client.GetCashBalancesContext(ctx)
+	})
+}
+
+// GetShareBalances calls GetShareBalancesContext for all accounts concurrently.
+// Results and errors are keyed by username; a failing account does not affect the others.
+func (m *AccountManager) GetShareBalances(ctx context.Context, portfolioType PortfolioType) map[string]AccountResult[*ShareBalanceResponse] {
+	return forEachAccount(ctx, m, func(ctx context.Context, client *Client) (*ShareBalanceResponse, error) {
+		return client.GetShareBalancesContext(ctx, portfolioType)
+	})
+}
+
+func forEachAccount[T any](ctx context.Context, m *AccountManager, fn func(context.Context, *Client) (T, error)) map[string]AccountResult[T] {
+	m.mu.RLock()
+	clients := maps.Clone(m.clients)
+	m.mu.RUnlock()
+
+	var (
+		mu      sync.Mutex
+		group   errgroup.Group
+		results = make(map[string]AccountResult[T], len(clients))
+	)
+
+	group.SetLimit(m.opts.MaxConcurrency)
+
+	for username, client := range clients {
+		group.Go(func() error {
+			data, err := fn(ctx, client)
+
+			mu.Lock()
+			results[username] = AccountResult[T]{Data: data, Err: err}
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	return results
+}
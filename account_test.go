@@ -0,0 +1,120 @@
+package goksei_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestAccountManager(t *testing.T) {
+	server := newTestServer(t)
+	for _, username := range []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"} {
+		server.AddAccount(kseitest.SampleAccount(username, testPassword))
+	}
+
+	// track the peak number of requests handled at once, slowing them down so they overlap
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+	)
+
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+
+	authStore := goksei.NewMemoryAuthStore()
+	transport := &countingTransport{}
+
+	manager := goksei.NewAccountManager(goksei.AccountManagerOpts{
+		ClientOpts: goksei.ClientOpts{
+			AuthStore:  authStore,
+			HTTPClient: &http.Client{Transport: transport},
+		},
+		MaxConcurrency: 2,
+	})
+
+	accounts := []goksei.Account{
+		{Username: "alice@example.com", Password: kseitest.HashPassword(testPassword)},
+		{Username: "bob@example.com", Password: kseitest.HashPassword(testPassword)},
+		{Username: "carol@example.com", Password: kseitest.HashPassword("wrong-password")},
+		{Username: "dave@example.com", Password: kseitest.HashPassword(testPassword)},
+	}
+
+	for _, account := range accounts {
+		manager.Add(account).SetBaseURL(server.URL)
+	}
+
+	results := manager.GetPortfolioSummary(t.Context())
+
+	if len(results) != len(accounts) {
+		t.Fatalf("GetPortfolioSummary() returned %d results, want %d", len(results), len(accounts))
+	}
+
+	for _, username := range []string{"alice@example.com", "bob@example.com", "dave@example.com"} {
+		if result := results[username]; result.Err != nil || result.Data.Total != sampleTotal {
+			t.Errorf("GetPortfolioSummary()[%q] = %+v, want a summary", username, result)
+		}
+	}
+
+	if err := results["carol@example.com"].Err; !errors.Is(err, goksei.ErrInvalidCredentials) {
+		t.Errorf("GetPortfolioSummary()[carol] error = %v, want %v", err, goksei.ErrInvalidCredentials)
+	}
+
+	// every client logs in through the shared transport and caches its session in the shared store
+	if got := transport.count("/login"); got != len(accounts) {
+		t.Errorf("logins through the shared transport = %v, want %v", got, len(accounts))
+	}
+
+	for _, username := range []string{"alice@example.com", "bob@example.com", "dave@example.com"} {
+		var session goksei.Session
+		if found, err := authStore.Get(username, &session); err != nil || !found {
+			t.Errorf("shared AuthStore has no session of %q: %v", username, err)
+		}
+	}
+
+	manager.Remove("carol@example.com")
+
+	cash := manager.GetCashBalances(t.Context())
+	if len(cash) != 3 {
+		t.Errorf("GetCashBalances() returned %d results, want %d", len(cash), 3)
+	}
+
+	for username, result := range cash {
+		if result.Err != nil || result.Data == nil {
+			t.Errorf("GetCashBalances()[%q] = %+v, want balances", username, result)
+		}
+	}
+
+	for username, result := range manager.GetShareBalances(t.Context(), goksei.EquityType) {
+		if result.Err != nil {
+			t.Errorf("GetShareBalances()[%q] error = %v", username, result.Err)
+		}
+	}
+
+	if got := manager.Usernames(); len(got) != 3 || got[0] != "alice@example.com" {
+		t.Errorf("Usernames() = %v, want alice, bob and dave", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if peak > 2 {
+		t.Errorf("peak requests in flight = %v, want at most %v", peak, 2)
+	}
+}
@@ -0,0 +1,199 @@
+package goksei
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrDecryption is returned by an encrypted AuthStore when a stored value cannot be
+// decrypted, either because it was tampered with or because none of the keys match.
+// Client treats such a value as missing and overwrites it after logging in again.
+var ErrDecryption = errors.New("cannot decrypt auth store value")
+
+const (
+	encryptionKeyLength = 32 // AES-256
+	pbkdf2Iterations    = 600_000
+	keyFileInfo         = "goksei auth store key"
+)
+
+// DeriveKey derives an encryption key for NewEncryptedAuthStore from a passphrase
+// using PBKDF2-HMAC-SHA256. The salt should be random, at least 16 bytes long,
+// and kept alongside the store: the same passphrase and salt always yield the same key.
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is required")
+	}
+
+	return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, encryptionKeyLength)
+}
+
+// ReadKeyFile reads a key file and derives an encryption key for NewEncryptedAuthStore
+// from its contents using HKDF-SHA256. The file must not be accessible by group or others.
+// Random bytes work best as contents, e.g. generated with "openssl rand 32 > keyfile".
+func ReadKeyFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("key file %s is accessible by other users (mode %s)", path, info.Mode().Perm())
+	}
+
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+
+	return hkdf.Key(sha256.New, secret, nil, keyFileInfo, encryptionKeyLength)
+}
+
+// encryptedValue is what an encrypted AuthStore writes to the underlying store.
+type encryptedValue struct {
+	KeyID string `json:"kid"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+type encryptedAuthStore struct {
+	store AuthStore
+	keys  []encryptionKey
+}
+
+// NewEncryptedAuthStore wraps store so that values are encrypted with AES-256-GCM before
+// being written, e.g. on top of NewFileAuthStore. Keys must be 32 bytes long,
+// see DeriveKey and ReadKeyFile.
+//
+// The first key encrypts new values while all keys are tried for decryption.
+// To rotate keys, put the new key first followed by the old ones: values written
+// with an old key are re-encrypted with the new key the next time they are read.
+//
+// It is the only kind of store in which ClientOpts.CachePasswordHash caches password hashes.
+func NewEncryptedAuthStore(store AuthStore, keys ...[]byte) (AuthStore, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+
+	s := &encryptedAuthStore{store: store}
+
+	for _, key := range keys {
+		if len(key) != encryptionKeyLength {
+			return nil, fmt.Errorf("invalid key length %d, must be %d bytes", len(key), encryptionKeyLength)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(key)
+		s.keys = append(s.keys, encryptionKey{
+			id:   hex.EncodeToString(sum[:4]),
+			aead: aead,
+		})
+	}
+
+	return s, nil
+}
+
+// Set encrypts v and stores it under k. The key name is authenticated as well,
+// so a value cannot be moved to another username.
+// isEncryptedAuthStore reports whether store was created with NewEncryptedAuthStore.
+func isEncryptedAuthStore(store AuthStore) bool {
+	_, ok := store.(*encryptedAuthStore)
+
+	return ok
+}
+
+func (s *encryptedAuthStore) Set(k string, v any) error {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	key := s.keys[0]
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	return s.store.Set(k, encryptedValue{
+		KeyID: key.id,
+		Nonce: nonce,
+		Data:  key.aead.Seal(nil, nonce, plaintext, []byte(k)),
+	})
+}
+
+// Get decrypts the value stored under k into v.
+func (s *encryptedAuthStore) Get(k string, v any) (bool, error) {
+	var value encryptedValue
+
+	found, err := s.store.Get(k, &value)
+	if err != nil || !found {
+		return found, err
+	}
+
+	for i, key := range s.keys {
+		if key.id != value.KeyID {
+			continue
+		}
+
+		plaintext, err := key.aead.Open(nil, value.Nonce, value.Data, []byte(k))
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrDecryption, err)
+		}
+
+		if err := json.Unmarshal(plaintext, v); err != nil {
+			return false, err
+		}
+
+		// written with an old key, re-encrypt with the current one
+		if i > 0 {
+			if err := s.Set(k, v); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+
+	return false, fmt.Errorf("%w: unknown key id %q", ErrDecryption, value.KeyID)
+}
+
+// Delete removes the value stored under k.
+func (s *encryptedAuthStore) Delete(k string) error {
+	return s.store.Delete(k)
+}
+
+// Close closes the underlying store.
+func (s *encryptedAuthStore) Close() error {
+	return s.store.Close()
+}
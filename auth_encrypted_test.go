@@ -0,0 +1,218 @@
+package goksei_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+)
+
+func newTestEncryptedStore(t *testing.T, keys ...[]byte) (goksei.AuthStore, goksei.AuthStore) {
+	t.Helper()
+
+	fileStore, err := goksei.NewFileAuthStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := goksei.NewEncryptedAuthStore(fileStore, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, fileStore
+}
+
+func TestEncryptedAuthStore(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	store, fileStore := newTestEncryptedStore(t, key)
+
+	if err := store.Set("alice", "secret-token"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	var token string
+	if found, err := store.Get("alice", &token); err != nil || !found || token != "secret-token" {
+		t.Errorf("Get() = %v, %v, %v, want %v", token, found, err, "secret-token")
+	}
+
+	var raw goksei.EncryptedValue
+	if _, err := fileStore.Get("alice", &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(raw.Data, []byte("secret-token")) {
+		t.Errorf("value is stored in plain text")
+	}
+
+	if found, err := store.Get("bob", &token); err != nil || found {
+		t.Errorf("Get() of missing key = %v, %v, want not found", found, err)
+	}
+}
+
+func TestEncryptedAuthStore_tampered(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name   string
+		tamper func(fileStore goksei.AuthStore) error
+	}{
+		{
+			name: "flipped_ciphertext_bit",
+			tamper: func(fileStore goksei.AuthStore) error {
+				var raw goksei.EncryptedValue
+				if _, err := fileStore.Get("alice", &raw); err != nil {
+					return err
+				}
+
+				raw.Data[0] ^= 1
+
+				return fileStore.Set("alice", raw)
+			},
+		},
+		{
+			name: "moved_to_another_username",
+			tamper: func(fileStore goksei.AuthStore) error {
+				var raw goksei.EncryptedValue
+				if _, err := fileStore.Get("bob", &raw); err != nil {
+					return err
+				}
+
+				return fileStore.Set("alice", raw)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, fileStore := newTestEncryptedStore(t, key)
+
+			if err := store.Set("alice", "alice-token"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.Set("bob", "bob-token"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.tamper(fileStore); err != nil {
+				t.Fatal(err)
+			}
+
+			var token string
+			if _, err := store.Get("alice", &token); !errors.Is(err, goksei.ErrDecryption) {
+				t.Errorf("Get() error = %v, want %v", err, goksei.ErrDecryption)
+			}
+		})
+	}
+}
+
+func TestEncryptedAuthStore_rotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	oldStore, fileStore := newTestEncryptedStore(t, oldKey)
+
+	if err := oldStore.Set("alice", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	newOnlyStore, err := goksei.NewEncryptedAuthStore(fileStore, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var token string
+	if _, err := newOnlyStore.Get("alice", &token); !errors.Is(err, goksei.ErrDecryption) {
+		t.Fatalf("Get() with unknown key error = %v, want %v", err, goksei.ErrDecryption)
+	}
+
+	rotatingStore, err := goksei.NewEncryptedAuthStore(fileStore, newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rotatingStore.Get("alice", &token); err != nil || token != "secret-token" {
+		t.Fatalf("Get() with rotated keys = %v, %v, want %v", token, err, "secret-token")
+	}
+
+	// the value has been re-encrypted with the new key
+	token = ""
+	if _, err := newOnlyStore.Get("alice", &token); err != nil || token != "secret-token" {
+		t.Errorf("Get() after rotation = %v, %v, want %v", token, err, "secret-token")
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	private := filepath.Join(dir, "private.key")
+	if err := os.WriteFile(private, []byte("some random bytes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	public := filepath.Join(dir, "public.key")
+	if err := os.WriteFile(public, []byte("some random bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := goksei.ReadKeyFile(private)
+	if err != nil || len(key) != goksei.EncryptionKeyLength {
+		t.Errorf("ReadKeyFile() = %v, %v, want a %d bytes key", key, err, goksei.EncryptionKeyLength)
+	}
+
+	if _, err := goksei.ReadKeyFile(public); err == nil {
+		t.Errorf("ReadKeyFile() accepted a world-readable key file")
+	}
+}
+
+func TestClient_undecryptableSession(t *testing.T) {
+	server := newTestServer(t)
+
+	oldStore, fileStore := newTestEncryptedStore(t, bytes.Repeat([]byte{1}, 32))
+
+	// a session and a password hash written with a key that has since been removed
+	if err := oldStore.Set(testUsername, goksei.Session{Token: newTestToken(t, time.Now().Add(time.Hour), 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := oldStore.Set(testUsername+goksei.PasswordHashKeySuffix, map[string]string{"hash": "stale-hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	newStore, err := goksei.NewEncryptedAuthStore(fileStore, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := server.NewClient(goksei.ClientOpts{
+		AuthStore:         newStore,
+		Username:          testUsername,
+		Password:          testPassword,
+		PlainPassword:     true,
+		CachePasswordHash: true,
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	logins, activations := server.LoginCount(), server.RequestCount("/activation/generated")
+	if logins != 1 || activations != 1 {
+		t.Errorf("logins = %v, activations = %v, want %v, %v", logins, activations, 1, 1)
+	}
+
+	// both entries have been overwritten with the new key
+	var session goksei.Session
+	if found, err := newStore.Get(testUsername, &session); err != nil || !found {
+		t.Errorf("Get(session) = %v, %v, want a session", found, err)
+	}
+
+	if hash, err := client.CachedPasswordHash(testPassword); err != nil || hash == "" {
+		t.Errorf("CachedPasswordHash() = %q, %v, want a hash", hash, err)
+	}
+}
@@ -0,0 +1,100 @@
+package goksei
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	data     []byte
+	expireAt time.Time // zero if the value never expires
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+type memoryAuthStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryAuthStore creates an authentication token store that keeps tokens in process memory,
+// for serverless and test usage where nothing should be written to disk.
+// Tokens are evicted once their JWT exp claim has passed. The store is safe for concurrent use
+// and can be shared by many clients.
+func NewMemoryAuthStore() AuthStore {
+	return &memoryAuthStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// Set stores v under k. Values are stored JSON-encoded, like the other AuthStore implementations.
+func (s *memoryAuthStore) Set(k string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	entry := memoryEntry{data: data}
+	if expireAt, ok := valueExpireTime(v); ok {
+		entry.expireAt = expireAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired(time.Now())
+	s.entries[k] = entry
+
+	return nil
+}
+
+// Get decodes the value stored under k into v, unless it has expired.
+func (s *memoryAuthStore) Get(k string, v any) (bool, error) {
+	s.mu.Lock()
+
+	entry, ok := s.entries[k]
+	if ok && entry.expired(time.Now()) {
+		delete(s.entries, k)
+
+		ok = false
+	}
+
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(entry.data, v)
+}
+
+// Delete removes the value stored under k.
+func (s *memoryAuthStore) Delete(k string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, k)
+
+	return nil
+}
+
+// Close releases all stored values.
+func (s *memoryAuthStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.entries)
+
+	return nil
+}
+
+func (s *memoryAuthStore) evictExpired(now time.Time) {
+	for k, entry := range s.entries {
+		if entry.expired(now) {
+			delete(s.entries, k)
+		}
+	}
+}
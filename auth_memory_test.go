@@ -0,0 +1,84 @@
+package goksei_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestMemoryAuthStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantFound bool
+	}{
+		{name: "valid_token", value: newTestToken(t, time.Now().Add(time.Hour), 1), wantFound: true},
+		{name: "expired_token", value: newTestToken(t, time.Now().Add(-time.Second), 1), wantFound: false},
+		{name: "not_a_token", value: "some value", wantFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := goksei.NewMemoryAuthStore()
+
+			if err := store.Set("alice", tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			var got string
+
+			found, err := store.Get("alice", &got)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			if found != tt.wantFound {
+				t.Errorf("Get() found = %v, want %v", found, tt.wantFound)
+			}
+
+			if found && got != tt.value {
+				t.Errorf("Get() = %v, want %v", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestMemoryAuthStore_sharedByClients(t *testing.T) {
+	server := newTestServer(t)
+	store := goksei.NewMemoryAuthStore()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		username := fmt.Sprintf("user%d@example.com", i%2)
+		server.AddAccount(kseitest.SampleAccount(username, testPassword))
+
+		client := server.NewClient(goksei.ClientOpts{
+			AuthStore: store,
+			Username:  username,
+			Password:  kseitest.HashPassword(testPassword),
+		})
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.GetPortfolioSummary(); err != nil {
+				t.Errorf("GetPortfolioSummary() error = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, username := range []string{"user0@example.com", "user1@example.com"} {
+		if found, err := store.Get(username, new(goksei.Session)); err != nil || !found {
+			t.Errorf("Get(%q) = %v, %v, want a cached token", username, found, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package goksei
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSQLAuthTable = "goksei_auth"
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLAuthStoreOpts contains configuration options for NewSQLAuthStore.
+type SQLAuthStoreOpts struct {
+	TableName          string // default: "goksei_auth"
+	DollarPlaceholders bool   // use $1, $2, ... placeholders (PostgreSQL) instead of ?
+}
+
+// SQLAuthStore is an AuthStore backed by database/sql, letting several instances of a service
+// share tokens instead of each logging in separately. It requires INSERT ... ON CONFLICT,
+// so it works with SQLite (e.g. for local testing) and PostgreSQL; MySQL is not supported.
+//
+// There is no optimistic locking: writes are single atomic upserts, so concurrent writers never
+// fail because of each other, and the expiry decides which one wins. A value holding a JWT only
+// replaces a stored one expiring no later than itself, so a replica finishing a slow login
+// cannot overwrite the newer token of another. Values holding a JWT also record their expiry
+// so that stale entries are ignored on read and can be removed with DeleteExpired.
+// Call Migrate once before use to create or upgrade the table.
+type SQLAuthStore struct {
+	db    *sql.DB
+	table string
+	opts  SQLAuthStoreOpts
+}
+
+// NewSQLAuthStore creates an authentication token store using db.
+// The store does not take ownership of db: Close leaves it open.
+func NewSQLAuthStore(db *sql.DB, opts SQLAuthStoreOpts) (*SQLAuthStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+
+	table := opts.TableName
+	if table == "" {
+		table = defaultSQLAuthTable
+	}
+
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	return &SQLAuthStore{
+		db:    db,
+		table: table,
+		opts:  opts,
+	}, nil
+}
+
+// Migrate creates the token table or upgrades it to the latest schema.
+// Applied migrations are recorded in a "<table>_migrations" table, so it is safe to call
+// Migrate on every start, including from several replicas starting at the same time.
+func (s *SQLAuthStore) Migrate(ctx context.Context) error {
+	return migrateSQL(ctx, s.db, s.table+"_migrations", s.opts.DollarPlaceholders, []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+			name VARCHAR(255) NOT NULL PRIMARY KEY,
+			value TEXT NOT NULL,
+			expires_at BIGINT
+		)`,
+	})
+}
+
+// Set stores v under k, JSON-encoded, unless a value expiring later is already stored.
+func (s *SQLAuthStore) Set(k string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt sql.NullInt64
+	if expireTime, ok := valueExpireTime(v); ok {
+		expiresAt = sql.NullInt64{Int64: expireTime.Unix(), Valid: true}
+	}
+
+	_, err = s.db.ExecContext(context.Background(), s.query(`INSERT INTO `+s.table+` (name, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			value = excluded.value,
+			expires_at = excluded.expires_at
+		WHERE excluded.expires_at IS NULL
+			OR `+s.table+`.expires_at IS NULL
+			OR excluded.expires_at >= `+s.table+`.expires_at`),
+		k, string(data), expiresAt)
+
+	return err
+}
+
+// Get decodes the value stored under k into v. Expired values are reported as not found.
+func (s *SQLAuthStore) Get(k string, v any) (bool, error) {
+	var (
+		data      string
+		expiresAt sql.NullInt64
+	)
+
+	err := s.db.QueryRowContext(context.Background(), s.query(`SELECT value, expires_at FROM `+s.table+` WHERE name = ?`), k).
+		Scan(&data, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if expiresAt.Valid && expiresAt.Int64 <= time.Now().Unix() {
+		return false, nil
+	}
+
+	return true, json.Unmarshal([]byte(data), v)
+}
+
+// Delete removes the value stored under k.
+func (s *SQLAuthStore) Delete(k string) error {
+	_, err := s.db.ExecContext(context.Background(), s.query(`DELETE FROM `+s.table+` WHERE name = ?`), k)
+
+	return err
+}
+
+// DeleteExpired removes all values whose token has expired and returns how many were removed.
+func (s *SQLAuthStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.query(`DELETE FROM `+s.table+` WHERE expires_at <= ?`), time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Close is a no-op, the database is owned by the caller.
+func (s *SQLAuthStore) Close() error {
+	return nil
+}
+
+func (s *SQLAuthStore) query(q string) string {
+	return rebindSQL(q, s.opts.DollarPlaceholders)
+}
+
+// rebindSQL replaces ? placeholders with $1, $2, ... when dollar is set.
+func rebindSQL(q string, dollar bool) string {
+	if !dollar {
+		return q
+	}
+
+	var b strings.Builder
+
+	n := 0
+
+	for _, r := range q {
+		if r != '?' {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		n++
+
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// migrateSQL applies migrations not yet recorded in migrationsTable, in order.
+// Each migration runs in a transaction together with its bookkeeping row. Concurrent callers
+// may apply the same migration, so migrations must be idempotent (IF NOT EXISTS), and a version
+// recorded meanwhile by another caller is not an error.
+func migrateSQL(ctx context.Context, db *sql.DB, migrationsTable string, dollar bool, migrations []string) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+
+	var current int
+
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+migrationsTable).Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if err := applyMigration(ctx, db, migrationsTable, dollar, i+1, migrations[i]); err != nil {
+			return fmt.Errorf("error applying migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, migrationsTable string, dollar bool, version int, migration string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, rebindSQL(`INSERT INTO `+migrationsTable+` (version, applied_at) VALUES (?, ?)
+		ON CONFLICT (version) DO NOTHING`, dollar),
+		version, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
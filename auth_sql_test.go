@@ -0,0 +1,239 @@
+package goksei_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func newTestSQLDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "goksei.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite allows a single writer at a time
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newTestSQLAuthStore(t *testing.T) *goksei.SQLAuthStore {
+	t.Helper()
+
+	store, err := goksei.NewSQLAuthStore(newTestSQLDB(t), goksei.SQLAuthStoreOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// migrating twice must be a no-op
+	for i := 0; i < 2; i++ {
+		if err := store.Migrate(t.Context()); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+
+	return store
+}
+
+func TestSQLAuthStore(t *testing.T) {
+	store := newTestSQLAuthStore(t)
+
+	valid := newTestToken(t, time.Now().Add(time.Hour), 1)
+	expired := newTestToken(t, time.Now().Add(-time.Hour), 2)
+
+	for k, v := range map[string]string{"alice": expired, "bob": expired, "carol": "not a token"} {
+		if err := store.Set(k, v); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	// a later expiry replaces the stored value
+	if err := store.Set("alice", valid); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	tests := []struct {
+		key       string
+		want      string
+		wantFound bool
+	}{
+		{key: "alice", want: valid, wantFound: true},
+		{key: "bob", want: "", wantFound: false},
+		{key: "carol", want: "not a token", wantFound: true},
+		{key: "dave", want: "", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var got string
+
+			found, err := store.Get(tt.key, &got)
+			if err != nil || found != tt.wantFound || got != tt.want {
+				t.Errorf("Get() = %v, %v, %v, want %v, %v", got, found, err, tt.want, tt.wantFound)
+			}
+		})
+	}
+
+	deleted, err := store.DeleteExpired(t.Context())
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteExpired() = %v, %v, want %v", deleted, err, 1)
+	}
+
+	if err := store.Delete("alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if found, err := store.Get("alice", new(string)); err != nil || found {
+		t.Errorf("Get() after Delete() = %v, %v, want not found", found, err)
+	}
+}
+
+func TestSQLAuthStore_sharedByReplicas(t *testing.T) {
+	server := newTestServer(t)
+	store := newTestSQLAuthStore(t)
+
+	for i := 0; i < 3; i++ {
+		client := server.NewClient(goksei.ClientOpts{
+			AuthStore: store,
+			Username:  testUsername,
+			Password:  kseitest.HashPassword(testPassword),
+		})
+
+		if _, err := client.GetPortfolioSummary(); err != nil {
+			t.Fatalf("GetPortfolioSummary() error = %v", err)
+		}
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+func TestSQLAuthStore_concurrentMigrate(t *testing.T) {
+	db := newTestSQLDB(t)
+
+	authStore, err := goksei.NewSQLAuthStore(db, goksei.SQLAuthStoreOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotStore, err := goksei.NewSQLSnapshotStore(db, goksei.SQLSnapshotStoreOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replicas starting at the same time all migrate the same fresh database
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			if err := authStore.Migrate(t.Context()); err != nil {
+				t.Errorf("SQLAuthStore.Migrate() error = %v", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			if err := snapshotStore.Migrate(t.Context()); err != nil {
+				t.Errorf("SQLSnapshotStore.Migrate() error = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := authStore.Set("alice", "token"); err != nil {
+		t.Errorf("Set() after concurrent migrations error = %v", err)
+	}
+}
+
+func TestSQLAuthStore_concurrentSet(t *testing.T) {
+	store := newTestSQLAuthStore(t)
+
+	var wg sync.WaitGroup
+
+	latest := newTestToken(t, time.Now().Add(time.Hour+10*time.Second), 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			token := newTestToken(t, time.Now().Add(time.Hour+time.Duration(i)*time.Second), i)
+			if i == 9 {
+				token = latest
+			}
+
+			if err := store.Set("alice", token); err != nil {
+				t.Errorf("Set() error = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// whatever the order of the writes, the token expiring last wins
+	var got string
+	if found, err := store.Get("alice", &got); err != nil || !found || got != latest {
+		t.Errorf("Get() = %v, %v, %v, want the token expiring last", got, found, err)
+	}
+}
+
+func TestSQLAuthStore_keepsLaterExpiry(t *testing.T) {
+	store := newTestSQLAuthStore(t)
+
+	newer := newTestToken(t, time.Now().Add(2*time.Hour), 1)
+	older := newTestToken(t, time.Now().Add(time.Hour), 2)
+
+	for _, token := range []string{newer, older} {
+		if err := store.Set("alice", token); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	var got string
+	if _, err := store.Get("alice", &got); err != nil || got != newer {
+		t.Errorf("Get() = %v, %v, want the token expiring later", got, err)
+	}
+
+	// values without expiry always replace
+	for _, value := range []string{"first", "second"} {
+		if err := store.Set("bob", value); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	if _, err := store.Get("bob", &got); err != nil || got != "second" {
+		t.Errorf("Get() = %v, %v, want %v", got, err, "second")
+	}
+}
+
+func Test_rebindSQL(t *testing.T) {
+	q := `UPDATE t SET value = ? WHERE name = ? AND expires_at <= ?`
+
+	if got := goksei.RebindSQL(q, false); got != q {
+		t.Errorf("rebindSQL() = %v, want %v", got, q)
+	}
+
+	want := `UPDATE t SET value = $1 WHERE name = $2 AND expires_at <= $3`
+	if got := goksei.RebindSQL(q, true); got != want {
+		t.Errorf("rebindSQL() = %v, want %v", got, want)
+	}
+}
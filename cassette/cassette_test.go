@@ -0,0 +1,153 @@
+package cassette_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/cassette"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+const (
+	username = "user@example.com"
+	password = "secret"
+)
+
+func newClient(t *testing.T, baseURL string, transport http.RoundTripper) *goksei.Client {
+	t.Helper()
+
+	client := goksei.NewClient(goksei.ClientOpts{
+		AuthStore:     goksei.NewMemoryAuthStore(),
+		Username:      username,
+		Password:      password,
+		PlainPassword: true,
+		HTTPClient:    &http.Client{Transport: transport},
+	})
+	client.SetBaseURL(baseURL)
+
+	return client
+}
+
+func record(t *testing.T, dir string) (*goksei.PortfolioSummaryResponse, string) {
+	t.Helper()
+
+	server := kseitest.NewServer()
+	defer server.Close()
+
+	server.AddAccount(kseitest.SampleAccount(username, password))
+
+	recorder, err := cassette.NewRecorder(dir, cassette.RecorderOpts{ScrubFields: []string{"rekening"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newClient(t, server.URL, recorder)
+
+	summary, err := client.GetPortfolioSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetCashBalances(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetGlobalIdentity(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := client.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return summary, session.Token
+}
+
+func TestRecorder_scrubs(t *testing.T) {
+	dir := t.TempDir()
+
+	_, token := record(t, dir)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// activation, login, summary, cash, identity
+	if len(files) != 5 {
+		t.Fatalf("recorded %d interactions, want 5", len(files))
+	}
+
+	secrets := []string{
+		token,
+		username,
+		kseitest.HashPassword(password),
+		"IDD000000000000", // investor ID
+		"1234567890",      // cash account number, scrubbed through ScrubFields
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, secret := range secrets {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s contains %q", filepath.Base(file), secret)
+			}
+		}
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	dir := t.TempDir()
+
+	recorded, _ := record(t, dir)
+
+	replayer, err := cassette.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recording server is gone, everything is served from the cassette
+	client := newClient(t, "http://127.0.0.1:1", replayer)
+
+	summary, err := client.GetPortfolioSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Total != recorded.Total || len(summary.Details) != len(recorded.Details) {
+		t.Errorf("replayed summary = %+v, want %+v", summary, recorded)
+	}
+
+	cash, err := client.GetCashBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cash.Data) != 1 || cash.Data[0].AccountNumber != cassette.Redacted {
+		t.Errorf("replayed cash balances = %+v", cash.Data)
+	}
+
+	identity, err := client.GetGlobalIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identity.Identities) != 1 || identity.Identities[0].Email != cassette.Redacted {
+		t.Errorf("replayed identities = %+v", identity.Identities)
+	}
+
+	_, err = client.GetShareBalances(goksei.BondType)
+	if !errors.Is(err, cassette.ErrNoInteraction) {
+		t.Errorf("error = %v, want %v", err, cassette.ErrNoInteraction)
+	}
+}
@@ -2,40 +2,74 @@ package goksei
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/corpix/uarand"
-	"golang.org/x/sync/singleflight"
 )
 
 var (
 	defaultBaseReferer = "https://akses.ksei.co.id"
 	defaultBaseURL     = "https://akses.ksei.co.id/service"
 	defaultTimeout     = 30 * time.Second
+	defaultRefreshSkew = time.Minute
+
+	defaultMaxResponseSize int64 = 10 << 20 // 10 MiB
 )
 
 // Client provides access to the KSEI (Indonesian Central Securities Depository) API.
 // It handles authentication, token management, and provides methods to retrieve
 // portfolio information including cash balances, share holdings, and account details.
-// It uses singleflight to prevent duplicate concurrent requests to the same endpoint.
+// Concurrent requests to the same endpoint and concurrent logins for the same user
+// are deduplicated into a single request.
+// A Client is safe for concurrent use, including concurrent calls to its Set methods.
 type Client struct {
-	baseURL string
-	timeout time.Duration
+	authStore AuthStore
+
+	// guards cfg; operations work on a snapshot taken by config
+	mu  sync.RWMutex
+	cfg clientConfig
+
+	// deduplicates concurrent requests to the same endpoint
+	sfGroup flightGroup
 
-	authStore     AuthStore
-	username      string
-	password      string
-	plainPassword bool
+	// shares one login between concurrent callers, keyed by username
+	loginGroup flightGroup
 
-	// singleflight group to prevent duplicate concurrent requests
-	sfGroup singleflight.Group
+	// guards token reads and writes to authStore
+	tokenMu sync.RWMutex
+
+	refresherMu sync.Mutex
+	refresher   *refresher
+}
+
+// clientConfig holds the settings of a Client that can be changed after creation.
+type clientConfig struct {
+	baseURL     string
+	timeout     time.Duration
+	httpClient  *http.Client
+	retryPolicy *RetryPolicy
+	rateLimiter RateLimiter
+	refreshSkew time.Duration
+
+	maxResponseSize int64
+
+	username          string
+	credentials       CredentialProvider
+	credentialsGen    uint64 // incremented by SetCredentials, so stale logins can be told apart
+	plainPassword     bool
+	cachePasswordHash bool
+	otpProvider       OTPProvider
 }
 
 // ClientOpts contains configuration options for creating a new Client.
@@ -45,6 +79,39 @@ type ClientOpts struct {
 	Password      string
 	PlainPassword bool
 	Timeout       time.Duration // HTTP request timeout (default: 30s)
+	RefreshSkew   time.Duration // renew the token when less than this lifetime remains (default: 1m)
+
+	// MaxResponseSize limits how many bytes of a response body are read (default: 10 MiB).
+	// Larger responses fail with ErrResponseTooLarge.
+	MaxResponseSize int64
+
+	// Credentials supplies the password lazily, when a login is needed. Each call is
+	// bounded by Timeout. If set, Password is ignored. See NewEnvCredentialProvider and friends.
+	Credentials CredentialProvider
+
+	// CachePasswordHash keeps the hashed form of a plain password in the AuthStore,
+	// so KSEI's hashing service is only called once instead of on every login.
+	// The hash is as good as the password for logging in, so it is only cached in an
+	// AuthStore created with NewEncryptedAuthStore; with any other store it is ignored.
+	// ClearToken, Logout and SetCredentials remove the cached hash.
+	CachePasswordHash bool
+
+	// OTPProvider supplies one-time passwords when KSEI adds a verification step to the login.
+	// Without it, such logins fail with ErrOTPRequired.
+	OTPProvider OTPProvider
+
+	// HTTPClient is used for every request made by the client, allowing custom
+	// transports, proxies or TLS settings. Its own Timeout is left untouched;
+	// the Timeout option above is applied per request.
+	// If nil, a client sharing http.DefaultTransport is used.
+	HTTPClient *http.Client
+
+	// RetryPolicy controls retries of failed requests. If nil, requests are not retried.
+	RetryPolicy *RetryPolicy
+
+	// RateLimiter, if set, is waited on before every request, including retries.
+	// It can be shared between clients, see NewTokenBucketLimiter.
+	RateLimiter RateLimiter
 }
 
 // NewClient creates a new KSEI API client with the provided options.
@@ -55,31 +122,86 @@ func NewClient(opts ClientOpts) *Client {
 		timeout = defaultTimeout
 	}
 
+	refreshSkew := opts.RefreshSkew
+	if refreshSkew == 0 {
+		refreshSkew = defaultRefreshSkew
+	}
+
+	maxResponseSize := opts.MaxResponseSize
+	if maxResponseSize <= 0 {
+		maxResponseSize = defaultMaxResponseSize
+	}
+
+	credentials := opts.Credentials
+	if credentials == nil {
+		credentials = NewStaticCredentialProvider(opts.Password)
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
 	client := &Client{
-		baseURL:       defaultBaseURL,
-		timeout:       timeout,
-		authStore:     opts.AuthStore,
-		username:      opts.Username,
-		password:      opts.Password,
-		plainPassword: opts.PlainPassword,
+		authStore: opts.AuthStore,
+		cfg: clientConfig{
+			baseURL:           defaultBaseURL,
+			timeout:           timeout,
+			httpClient:        httpClient,
+			retryPolicy:       opts.RetryPolicy,
+			rateLimiter:       opts.RateLimiter,
+			refreshSkew:       refreshSkew,
+			maxResponseSize:   maxResponseSize,
+			username:          opts.Username,
+			credentials:       credentials,
+			plainPassword:     opts.PlainPassword,
+			cachePasswordHash: opts.CachePasswordHash && isEncryptedAuthStore(opts.AuthStore),
+			otpProvider:       opts.OTPProvider,
+		},
 	}
 
 	return client
 }
 
-func (c *Client) hashPassword() (string, error) {
-	if !c.plainPassword {
-		return c.password, nil
+// config returns a snapshot of the client settings, so that a single operation
+// sees consistent values even if a Set method is called concurrently.
+func (c *Client) config() clientConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cfg
+}
+
+// hashPassword returns the hashed form of password expected by the login endpoint,
+// and whether it was taken from the cache. A freshly requested hash is not cached here:
+// login stores it once KSEI has accepted it.
+func (c *Client) hashPassword(ctx context.Context, cfg clientConfig, password string) (string, bool, error) {
+	if !cfg.plainPassword {
+		return password, false, nil
 	}
 
-	passwordSHA1 := fmt.Sprintf("%x", sha1.Sum([]byte(c.password)))
+	if cfg.cachePasswordHash {
+		hashedPassword, err := c.cachedPasswordHash(cfg.username, password)
+		if err != nil || hashedPassword != "" {
+			return hashedPassword, hashedPassword != "", err
+		}
+	}
+
+	hashedPassword, err := c.requestPasswordHash(ctx, cfg, password)
+
+	return hashedPassword, false, err
+}
+
+// requestPasswordHash asks KSEI's activation service for the hashed form of a plain password.
+func (c *Client) requestPasswordHash(ctx context.Context, cfg clientConfig, password string) (string, error) {
+	passwordSHA1 := fmt.Sprintf("%x", sha1.Sum([]byte(password)))
 	timestamp := time.Now().Unix()
 	param := fmt.Sprintf("%s@@!!@@%d", passwordSHA1, timestamp)
 	encodedParam := base64.StdEncoding.EncodeToString([]byte(param))
 
-	url := fmt.Sprintf("%s/activation/generated?param=%s", c.baseURL, url.QueryEscape(encodedParam))
+	url := fmt.Sprintf("%s/activation/generated?param=%s", cfg.baseURL, url.QueryEscape(encodedParam))
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("error creating hashed password request: %w", err)
 	}
@@ -87,9 +209,7 @@ func (c *Client) hashPassword() (string, error) {
 	req.Header.Set("Referer", defaultBaseReferer)
 	req.Header.Set("User-Agent", uarand.GetRandom())
 
-	client := &http.Client{Timeout: c.timeout}
-
-	res, err := client.Do(req)
+	res, err := c.do(cfg, req)
 	if err != nil {
 		return "", fmt.Errorf("error getting hashed password: %w", err)
 	}
@@ -102,111 +222,427 @@ func (c *Client) hashPassword() (string, error) {
 		} `json:"data"`
 	}
 
-	if err := json.NewDecoder(res.Body).Decode(&activationResponse); err != nil {
+	if err := json.Unmarshal(res.body, &activationResponse); err != nil {
 		return "", fmt.Errorf("error decoding activation response body: %w", err)
 	}
 
 	if len(activationResponse.Data) == 0 {
-		return "", fmt.Errorf("no data found in activation response: %v", activationResponse)
+		return "", fmt.Errorf("%w: no data found in activation response: %v", ErrUnexpectedResponse, activationResponse)
 	}
 
 	return activationResponse.Data[0].Pass, nil
 }
 
-func (c *Client) login() (string, error) {
-	if c.username == "" || c.password == "" {
+// password asks the credential provider for the password of cfg.username. Like a request,
+// the call is bounded by the client timeout, so a hung helper cannot block logins forever.
+func (c *Client) password(ctx context.Context, cfg clientConfig) (string, error) {
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	return cfg.credentials.Password(ctx, cfg.username)
+}
+
+func (c *Client) login(ctx context.Context, cfg clientConfig) (string, error) {
+	if cfg.username == "" {
 		return "", fmt.Errorf("username and password are required")
 	}
 
-	hashedPassword, err := c.hashPassword()
+	password, err := c.password(ctx, cfg)
 	if err != nil {
+		return "", fmt.Errorf("error getting password: %w", err)
+	}
+
+	if password == "" {
+		return "", fmt.Errorf("username and password are required")
+	}
+
+	hashedPassword, cached, err := c.hashPassword(ctx, cfg, password)
+	if err != nil {
+		return "", err
+	}
+
+	// at most two attempts: the cached hash, then a fresh one if KSEI rejected it
+	token, err := c.postLogin(ctx, cfg, hashedPassword)
+	if cached && errors.Is(err, ErrInvalidCredentials) {
+		if err := c.purgePasswordHash(cfg.username); err != nil {
+			return "", err
+		}
+
+		if hashedPassword, err = c.requestPasswordHash(ctx, cfg, password); err != nil {
+			return "", err
+		}
+
+		cached = false
+		token, err = c.postLogin(ctx, cfg, hashedPassword)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.plainPassword && cfg.cachePasswordHash && !cached {
+		if err := c.storePasswordHash(cfg.username, password, hashedPassword); err != nil {
+			return "", err
+		}
+	}
+
+	session, err := newSession(cfg.username, token)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid token in login response: %w", ErrUnexpectedResponse, err)
+	}
+
+	if err := c.storeSession(cfg, session); err != nil {
 		return "", err
 	}
 
-	body, err := json.Marshal(LoginRequest{
-		Username: c.username,
+	return token, nil
+}
+
+// postLogin sends the login request and returns the issued token.
+// It completes the one-time password challenge if KSEI asks for one.
+func (c *Client) postLogin(ctx context.Context, cfg clientConfig, hashedPassword string) (string, error) {
+	res, err := c.postJSON(ctx, cfg, "/login?lang=id", LoginRequest{
+		Username: cfg.username,
 		Password: hashedPassword,
 		ID:       "1",
 		AppType:  "web",
 	})
+	if isLoginRejection(err) {
+		var loginResponse LoginResponse
+
+		// the rejection usually explains itself, but the body may not be JSON at all
+		_ = json.Unmarshal(res.body, &loginResponse)
+
+		return "", newLoginError(loginResponse, err)
+	}
+
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/login?lang=id", bytes.NewBuffer(body))
+	var loginResponse LoginResponse
+
+	if err := json.Unmarshal(res.body, &loginResponse); err != nil {
+		return "", fmt.Errorf("%w: error decoding login response body: %w", ErrUnexpectedResponse, err)
+	}
+
+	if loginResponse.OTPRequired {
+		return c.verifyOTP(ctx, cfg, loginResponse)
+	}
+
+	// never hand out, or cache, an empty token
+	token := loginResponse.Validation
+	if token == "" {
+		return "", newLoginError(loginResponse, nil)
+	}
+
+	return token, nil
+}
+
+// postJSON sends payload as a JSON POST request to path.
+func (c *Client) postJSON(ctx context.Context, cfg clientConfig, path string, payload any) (*response, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseURL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
 	}
 
 	req.Header.Set("Referer", defaultBaseReferer)
 	req.Header.Set("User-Agent", uarand.GetRandom())
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: c.timeout}
+	return c.do(cfg, req)
+}
+
+// sharedLogin performs login, deduplicated per username so that concurrent callers
+// with an expired token share a single authentication. Like GetContext, each caller
+// stops waiting once its own ctx is done, and the login is aborted once all of them are.
+func (c *Client) sharedLogin(ctx context.Context, cfg clientConfig) (string, error) {
+	token, err := c.loginGroup.Do(ctx, fmt.Sprintf("%s:%d", cfg.username, cfg.credentialsGen), func(ctx context.Context) (any, error) {
+		// a previous flight may have finished between our cache lookup and joining this one
+		if token, err := c.validToken(cfg); err != nil || token != "" {
+			return token, err
+		}
 
-	res, err := client.Do(req)
+		return c.login(ctx, cfg)
+	})
 	if err != nil {
 		return "", err
 	}
 
-	var loginResponse LoginResponse
+	return token.(string), nil
+}
+
+func (c *Client) getToken(ctx context.Context, cfg clientConfig) (string, error) {
+	token, err := c.validToken(cfg)
+	if err != nil || token != "" {
+		return token, err
+	}
+
+	return c.sharedLogin(ctx, cfg)
+}
 
-	if err := json.NewDecoder(res.Body).Decode(&loginResponse); err != nil {
+// validToken returns the cached token if it is not about to expire, or an empty string otherwise.
+func (c *Client) validToken(cfg clientConfig) (string, error) {
+	session, err := c.cachedSession(cfg.username)
+	if err != nil || session == nil {
 		return "", err
 	}
 
-	token := loginResponse.Validation
+	// renew ahead of time so the token does not expire mid-flight
+	if session.Remaining() < cfg.refreshSkew {
+		return "", nil
+	}
 
-	if c.authStore != nil {
-		if err := c.authStore.Set(c.username, token); err != nil {
-			return "", err
-		}
+	return session.Token, nil
+}
+
+// cachedToken returns the token of username from the AuthStore,
+// or an empty string if there is none.
+func (c *Client) cachedToken(username string) (string, error) {
+	session, err := c.cachedSession(username)
+	if err != nil || session == nil {
+		return "", err
 	}
 
-	return token, nil
+	return session.Token, nil
 }
 
-func (c *Client) getToken() (string, error) {
+// cachedSession returns the session of username from the AuthStore, or nil if there is none.
+func (c *Client) cachedSession(username string) (*Session, error) {
 	if c.authStore == nil {
-		return c.login()
+		return nil, nil
 	}
 
-	var token string
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
 
-	found, err := c.authStore.Get(c.username, &token)
-	if err != nil {
-		return "", err
+	var session Session
+
+	found, err := c.authStore.Get(username, &session)
+	if errors.Is(err, ErrDecryption) {
+		// e.g. written with a key that has since been removed: log in again and overwrite it
+		return nil, nil
+	}
+
+	if err != nil || !found || session.Token == "" {
+		return nil, err
+	}
+
+	// sessions stored as a bare token by previous versions lack the username
+	session.Username = username
+
+	return &session, nil
+}
+
+// storeSession saves session to the AuthStore under its username, unless the credentials
+// of cfg have been replaced while logging in: SetCredentials has already purged the tokens
+// and a session obtained with the old credentials must not come back.
+func (c *Client) storeSession(cfg clientConfig, session *Session) error {
+	if c.authStore == nil {
+		return nil
 	}
 
-	if !found || token == "" {
-		return c.login()
+	// held until the session is stored, so SetCredentials cannot purge in between
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cfg.credentialsGen != cfg.credentialsGen {
+		return nil
 	}
 
-	expire, err := getExpireTime(token)
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.authStore.Set(session.Username, session)
+}
+
+// response is a fully read HTTP response.
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// do sends req through the shared HTTP client with the configured timeout applied,
+// then reads and closes the response body so the connection can be reused.
+// Non-2xx responses are returned as *ResponseError, together with the response itself.
+// Failed attempts are retried according to the client's RetryPolicy.
+func (c *Client) do(cfg clientConfig, req *http.Request) (*response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := c.doOnce(cfg, req)
+		if err == nil {
+			return res, nil
+		}
+
+		if !cfg.retryPolicy.shouldRetry(req, attempt, err) {
+			return res, err
+		}
+
+		var header http.Header
+		if res != nil {
+			header = res.header
+		}
+
+		delay := cfg.retryPolicy.delay(attempt, header)
+
+		if cfg.retryPolicy.OnRetry != nil {
+			cfg.retryPolicy.OnRetry(RetryEvent{
+				Request: req,
+				Attempt: attempt,
+				Delay:   delay,
+				Err:     err,
+			})
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doOnce performs a single attempt of do. The response is also returned
+// alongside a *ResponseError so its headers can be inspected.
+func (c *Client) doOnce(cfg clientConfig, req *http.Request) (*response, error) {
+	if cfg.rateLimiter != nil {
+		key := RateLimitKey{BaseURL: cfg.baseURL, Username: cfg.username}
+		if err := cfg.rateLimiter.Wait(req.Context(), key); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), cfg.timeout)
+		defer cancel()
+
+		req = req.WithContext(ctx)
+	}
+
+	res, err := cfg.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	if expire.Before(time.Now()) {
-		return c.login()
+	maxResponseSize := cfg.maxResponseSize
+	if maxResponseSize <= 0 {
+		maxResponseSize = defaultMaxResponseSize
 	}
 
-	return token, nil
+	// Read the response body, one byte past the limit to tell whether it was exceeded
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(res.Body, maxResponseSize+1)); err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if int64(buf.Len()) > maxResponseSize {
+		return nil, fmt.Errorf("%w: more than %d bytes from %s", ErrResponseTooLarge, maxResponseSize, req.URL.Path)
+	}
+
+	resp := &response{
+		statusCode: res.StatusCode,
+		header:     res.Header,
+		body:       buf.Bytes(),
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return resp, newResponseError(res.StatusCode, buf.Bytes())
+	}
+
+	if isMaintenancePage(res.Header, buf.Bytes()) {
+		return resp, newMaintenanceError(res.Header, buf.Bytes())
+	}
+
+	return resp, nil
 }
 
-// singleflightKey generates a unique key for singleflight based on username and path
-func (c *Client) singleflightKey(path string) string {
-	return c.username + ":" + path
+// singleflightKey generates a unique key for sfGroup based on the credentials of cfg and path,
+// so requests made after SetCredentials never join one made with the previous credentials.
+func (c *Client) singleflightKey(cfg clientConfig, path string) string {
+	return fmt.Sprintf("%s:%d:%s", cfg.username, cfg.credentialsGen, path)
 }
 
-// doGet performs the actual HTTP GET request - used internally by singleflight
-func (c *Client) doGet(path string) ([]byte, error) {
-	token, err := c.getToken()
+// doGet performs the actual HTTP GET request - used internally by sfGroup.
+// KSEI can invalidate a session before its exp claim (e.g. when the same account logs in
+// from the browser), so an unauthorized response to the request purges the rejected token
+// and the request is retried once with the token cached meanwhile or a fresh login.
+// A failed login is never retried.
+func (c *Client) doGet(ctx context.Context, cfg clientConfig, path string) ([]byte, error) {
+	token, err := c.getToken(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	body, err := c.fetch(ctx, cfg, path, token)
+	if !errors.Is(err, ErrUnauthorized) {
+		return body, err
+	}
+
+	if err := c.purgeRejectedToken(cfg.username, token); err != nil {
+		return nil, err
+	}
+
+	if token, err = c.getToken(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	return c.fetch(ctx, cfg, path, token)
+}
+
+// purgeRejectedToken removes the cached token of username from the AuthStore if it is
+// still the rejected token. A token cached meanwhile by a concurrent login, possibly from
+// another process sharing the AuthStore, is kept and used instead of logging in again.
+func (c *Client) purgeRejectedToken(username, rejected string) error {
+	if c.authStore == nil {
+		return nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	var session Session
+
+	found, err := c.authStore.Get(username, &session)
+	if err == nil && found && session.Token != rejected {
+		return nil
+	}
+
+	return c.authStore.Delete(username)
+}
+
+// purgeCredentials removes everything cached for username from the AuthStore:
+// its token and its password hash.
+func (c *Client) purgeCredentials(username string) error {
+	return errors.Join(c.purgeToken(username), c.purgePasswordHash(username))
+}
+
+// purgeToken removes the cached token of username from the AuthStore.
+func (c *Client) purgeToken(username string) error {
+	if c.authStore == nil {
+		return nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.authStore.Delete(username)
+}
+
+// fetch performs a single GET request authenticated with token.
+func (c *Client) fetch(ctx context.Context, cfg clientConfig, path, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.baseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -215,41 +651,42 @@ func (c *Client) doGet(path string) ([]byte, error) {
 	req.Header.Set("User-Agent", uarand.GetRandom())
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{Timeout: c.timeout}
-
-	res, err := client.Do(req)
+	res, err := c.do(cfg, req)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	// Read the response body
-	var buf bytes.Buffer
-	if _, err := buf.ReadFrom(res.Body); err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
-	}
 
-	return buf.Bytes(), nil
+	return res.body, nil
 }
 
 // Get performs an authenticated GET request to the specified API path and decodes
 // the JSON response into dst. It automatically handles authentication and token refresh.
-// Uses singleflight to prevent duplicate concurrent requests to the same endpoint.
+// Failures are reported with the sentinel errors in this package, see ResponseError.
+// Concurrent requests to the same endpoint are deduplicated into a single request.
 func (c *Client) Get(path string, dst any) error {
-	// Use singleflight to prevent duplicate concurrent requests
-	key := c.singleflightKey(path)
-	result, err, _ := c.sfGroup.Do(key, func() (any, error) {
-		return c.doGet(path)
-	})
+	return c.GetContext(context.Background(), path, dst)
+}
 
+// GetContext is like Get but carries ctx through token acquisition, password hashing and
+// the request itself. Concurrent callers of the same path share a single request; each
+// caller stops waiting as soon as its own ctx is done, while the shared request keeps
+// running (bounded by the client timeout) for the remaining callers. The shared request
+// is aborted once every caller waiting for it is done.
+func (c *Client) GetContext(ctx context.Context, path string, dst any) error {
+	// The shared request must not be cancelled by whichever caller happened to start it.
+	cfg := c.config()
+	key := c.singleflightKey(cfg, path)
+	body, err := c.sfGroup.Do(ctx, key, func(ctx context.Context) (any, error) {
+		return c.doGet(ctx, cfg, path)
+	})
 	if err != nil {
 		return err
 	}
 
 	// Decode the response body into dst
-	responseBody := result.([]byte)
+	responseBody := body.([]byte)
 	if err := json.Unmarshal(responseBody, dst); err != nil {
-		return fmt.Errorf("error decoding body: %w", err)
+		return fmt.Errorf("%w: error decoding body: %w", ErrUnexpectedResponse, err)
 	}
 
 	return nil
@@ -257,36 +694,187 @@ func (c *Client) Get(path string, dst any) error {
 
 // SetAuth updates the client's authentication credentials.
 // This will invalidate any cached tokens and require re-authentication on the next API call.
+// Use SetCredentials to find out whether the cached tokens could be removed.
 func (c *Client) SetAuth(username, password string) {
-	c.username = username
-	c.password = password
+	_ = c.SetCredentials(username, NewStaticCredentialProvider(password))
+}
+
+// SetCredentials is like SetAuth but takes a CredentialProvider supplying the password.
+// The returned error reports a failure to remove cached tokens from the AuthStore;
+// the new credentials are applied regardless. Logins still in flight with the previous
+// credentials do not store their token.
+func (c *Client) SetCredentials(username string, credentials CredentialProvider) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.cfg.username
+	c.cfg.username = username
+	c.cfg.credentials = credentials
+	c.cfg.credentialsGen++
+
+	// a token cached for the new username may have been obtained with another password
+	return errors.Join(c.purgeCredentials(previous), c.purgeCredentials(username))
+}
+
+// Session returns the cached session of the current user, e.g. to show who is logged in
+// and until when. It never logs in: ErrNoSession is returned if no unexpired session is
+// cached, which is always the case without an AuthStore.
+func (c *Client) Session() (*Session, error) {
+	session, err := c.cachedSession(c.config().username)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || session.Expired() {
+		return nil, ErrNoSession
+	}
+
+	return session, nil
+}
+
+// ClearToken removes the cached token and password hash of the current user from the
+// AuthStore, forcing a fresh login on the next API call. The KSEI session itself is left alone;
+// use Logout to end it as well. The background refresher, if running, is stopped so that
+// it does not log in again right away; call StartRefresher to resume it.
+func (c *Client) ClearToken() error {
+	c.StopRefresher()
+
+	return c.purgeCredentials(c.config().username)
+}
+
+// Logout ends the current KSEI session and removes its token and password hash from the AuthStore.
+// Like ClearToken, it stops the background refresher.
+func (c *Client) Logout() error {
+	return c.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout but uses ctx for the request.
+// The cached token is removed even if KSEI fails to end the session. A session that
+// is already invalid, or a missing logout endpoint, is not reported as an error.
+func (c *Client) LogoutContext(ctx context.Context) error {
+	c.StopRefresher()
+
+	cfg := c.config()
+
+	token, err := c.cachedToken(cfg.username)
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		err = c.logout(ctx, cfg, token)
+	}
+
+	return errors.Join(err, c.purgeCredentials(cfg.username))
+}
+
+func (c *Client) logout(ctx context.Context, cfg clientConfig, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseURL+"/logout", nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Referer", defaultBaseReferer)
+	req.Header.Set("User-Agent", uarand.GetRandom())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	_, err = c.do(cfg, req)
+
+	var respErr *ResponseError
+	if errors.As(err, &respErr) && (respErr.StatusCode == http.StatusNotFound || errors.Is(err, ErrUnauthorized)) {
+		return nil
+	}
+
+	return err
 }
 
 // SetBaseURL updates the base URL for API requests.
 // This is primarily useful for testing or if KSEI changes their API endpoint.
 func (c *Client) SetBaseURL(baseURL string) {
-	c.baseURL = baseURL
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.baseURL = baseURL
 }
 
 // SetPlainPassword configures whether the password should be automatically hashed.
 // When true, the client will hash plain text passwords using KSEI's hashing service.
 // When false, the password is expected to be pre-hashed.
 func (c *Client) SetPlainPassword(plainPassword bool) {
-	c.plainPassword = plainPassword
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.plainPassword = plainPassword
+}
+
+// SetOTPProvider replaces the provider of one-time passwords used to complete login challenges.
+func (c *Client) SetOTPProvider(otpProvider OTPProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.otpProvider = otpProvider
+}
+
+// SetHTTPClient replaces the HTTP client used for all API calls.
+// Passing nil restores a default client sharing http.DefaultTransport.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.httpClient = httpClient
+}
+
+// SetRetryPolicy replaces the retry policy used for all API calls.
+// Passing nil disables retries.
+func (c *Client) SetRetryPolicy(retryPolicy *RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.retryPolicy = retryPolicy
+}
+
+// SetRateLimiter replaces the rate limiter used for all API calls.
+// Passing nil disables client-side rate limiting.
+func (c *Client) SetRateLimiter(rateLimiter RateLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.rateLimiter = rateLimiter
 }
 
 // SetTimeout configures the HTTP request timeout for all API calls.
 // A timeout of 0 means no timeout. The default timeout is 30 seconds.
 func (c *Client) SetTimeout(timeout time.Duration) {
-	c.timeout = timeout
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.timeout = timeout
+}
+
+// SetMaxResponseSize changes how many bytes of a response body are read.
+// Zero or a negative size restores the default of 10 MiB.
+func (c *Client) SetMaxResponseSize(size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg.maxResponseSize = size
 }
 
 // GetPortfolioSummary retrieves a summary of all portfolio holdings including
 // total values and breakdown by asset type (equity, mutual funds, bonds, etc.).
 func (c *Client) GetPortfolioSummary() (*PortfolioSummaryResponse, error) {
+	return c.GetPortfolioSummaryContext(context.Background())
+}
+
+// GetPortfolioSummaryContext is like GetPortfolioSummary but uses ctx for the request.
+func (c *Client) GetPortfolioSummaryContext(ctx context.Context) (*PortfolioSummaryResponse, error) {
 	var response PortfolioSummaryResponse
 
-	if err := c.Get("/myportofolio/summary", &response); err != nil {
+	if err := c.GetContext(ctx, "/myportofolio/summary", &response); err != nil {
 		return nil, err
 	}
 
@@ -296,9 +884,14 @@ func (c *Client) GetPortfolioSummary() (*PortfolioSummaryResponse, error) {
 // GetCashBalances retrieves detailed cash balance information across all accounts,
 // including different currencies and custodian banks.
 func (c *Client) GetCashBalances() (*CashBalanceResponse, error) {
+	return c.GetCashBalancesContext(context.Background())
+}
+
+// GetCashBalancesContext is like GetCashBalances but uses ctx for the request.
+func (c *Client) GetCashBalancesContext(ctx context.Context) (*CashBalanceResponse, error) {
 	var response CashBalanceResponse
 
-	if err := c.Get("/myportofolio/summary-detail/"+strings.ToLower(string(CashType)), &response); err != nil {
+	if err := c.GetContext(ctx, "/myportofolio/summary-detail/"+strings.ToLower(string(CashType)), &response); err != nil {
 		return nil, err
 	}
 
@@ -309,13 +902,18 @@ func (c *Client) GetCashBalances() (*CashBalanceResponse, error) {
 // Valid portfolio types are EquityType, MutualFundType, BondType, and OtherType.
 // Use GetCashBalances() for cash holdings instead.
 func (c *Client) GetShareBalances(portfolioType PortfolioType) (*ShareBalanceResponse, error) {
+	return c.GetShareBalancesContext(context.Background(), portfolioType)
+}
+
+// GetShareBalancesContext is like GetShareBalances but uses ctx for the request.
+func (c *Client) GetShareBalancesContext(ctx context.Context, portfolioType PortfolioType) (*ShareBalanceResponse, error) {
 	if portfolioType == CashType {
 		return nil, fmt.Errorf("GetShareBalances does not accept cash type")
 	}
 
 	var response ShareBalanceResponse
 
-	if err := c.Get("/myportofolio/summary-detail/"+strings.ToLower(string(portfolioType)), &response); err != nil {
+	if err := c.GetContext(ctx, "/myportofolio/summary-detail/"+strings.ToLower(string(portfolioType)), &response); err != nil {
 		return nil, err
 	}
 
@@ -327,9 +925,14 @@ func (c *Client) GetShareBalances(portfolioType PortfolioType) (*ShareBalanceRes
 // GetGlobalIdentity retrieves detailed account and identity information
 // including investor ID, tax numbers, and other personal details.
 func (c *Client) GetGlobalIdentity() (*GlobalIdentityResponse, error) {
+	return c.GetGlobalIdentityContext(context.Background())
+}
+
+// GetGlobalIdentityContext is like GetGlobalIdentity but uses ctx for the request.
+func (c *Client) GetGlobalIdentityContext(ctx context.Context) (*GlobalIdentityResponse, error) {
 	var identity GlobalIdentityResponse
 
-	if err := c.Get("/myaccount/global-identity/", &identity); err != nil {
+	if err := c.GetContext(ctx, "/myaccount/global-identity/", &identity); err != nil {
 		return nil, err
 	}
 
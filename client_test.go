@@ -0,0 +1,672 @@
+package goksei_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testUsername = "user@example.com"
+	testPassword = "plain-password"
+
+	// portfolio total of kseitest.SampleAccount
+	sampleTotal = 7_950_625
+)
+
+// newTestServer starts a kseitest.Server serving kseitest.SampleAccount for the test user.
+func newTestServer(t *testing.T) *kseitest.Server {
+	t.Helper()
+
+	server := kseitest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddAccount(kseitest.SampleAccount(testUsername, testPassword))
+
+	return server
+}
+
+// newTestToken returns a JWT that is well-formed but was not issued by any kseitest.Server.
+func newTestToken(t *testing.T, exp time.Time, id int) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": exp.Unix(),
+		"jti": fmt.Sprint(id),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// newTestClient creates a client of the test user with a hashed password and a file AuthStore.
+func newTestClient(t *testing.T, server *kseitest.Server) *goksei.Client {
+	t.Helper()
+
+	authStore, err := goksei.NewFileAuthStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server.NewClient(goksei.ClientOpts{
+		AuthStore: authStore,
+		Username:  testUsername,
+		Password:  kseitest.HashPassword(testPassword),
+	})
+}
+
+func TestClient_Get_reloginOnUnauthorized(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	stale := newTestToken(t, time.Now().Add(time.Hour), 0)
+	if err := client.AuthStore().Set(testUsername, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := client.GetPortfolioSummary()
+	if err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	if summary.Total != sampleTotal {
+		t.Errorf("GetPortfolioSummary() total = %v, want %v", summary.Total, sampleTotal)
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+
+	session, err := client.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.Token == stale {
+		t.Errorf("stale token was not purged from AuthStore")
+	}
+}
+
+func TestClient_Get_keepsTokenReplacedMeanwhile(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	// another replica has logged in and is about to share its token
+	replica := server.NewClient(goksei.ClientOpts{Username: testUsername, Password: kseitest.HashPassword(testPassword)})
+	if _, err := replica.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := replica.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := newTestToken(t, time.Now().Add(time.Hour), 0)
+	if err := client.AuthStore().Set(testUsername, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica stores its token while the request with the stale token is in flight
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer "+stale {
+			if err := client.AuthStore().Set(testUsername, fresh); err != nil {
+				t.Error(err)
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want only the replica's", got)
+	}
+
+	if session, _ := client.Session(); session == nil || session.Token != fresh.Token {
+		t.Errorf("token replaced meanwhile was purged")
+	}
+}
+
+func TestClient_Get_unauthorizedAfterRelogin(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	// warm the cache, then reject every token including freshly issued ones
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Fail(kseitest.Failure{Path: "/myportofolio/summary", StatusCode: http.StatusUnauthorized})
+
+	_, err := client.GetPortfolioSummary()
+	if !errors.Is(err, goksei.ErrUnauthorized) {
+		t.Fatalf("GetPortfolioSummary() error = %v, want %v", err, goksei.ErrUnauthorized)
+	}
+
+	if got := server.LoginCount(); got != 2 {
+		t.Errorf("login count = %v, want %v", got, 2)
+	}
+}
+
+func TestClient_Get_concurrentLogin(t *testing.T) {
+	server := newTestServer(t)
+	server.SetLatency("/login", 50*time.Millisecond)
+	client := newTestClient(t, server)
+
+	paths := []string{"/myportofolio/summary"}
+	for _, portfolioType := range []goksei.PortfolioType{goksei.CashType, goksei.EquityType, goksei.MutualFundType, goksei.BondType, goksei.OtherType} {
+		paths = append(paths, "/myportofolio/summary-detail/"+strings.ToLower(string(portfolioType)))
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 2*len(paths))
+
+	for i := 0; i < 2; i++ {
+		for _, path := range paths {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				var dst map[string]any
+				errs <- client.Get(path, &dst)
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+func TestClient_SetAuth_invalidatesToken(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.AddAccount(kseitest.SampleAccount(testUsername, "new-plain-password"))
+	client.SetAuth(testUsername, kseitest.HashPassword("new-plain-password"))
+
+	if _, err := client.Session(); !errors.Is(err, goksei.ErrNoSession) {
+		t.Errorf("SetAuth() kept the cached token")
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.LoginCount(); got != 2 {
+		t.Errorf("login count = %v, want %v", got, 2)
+	}
+}
+
+func TestClient_SetCredentials_duringLogin(t *testing.T) {
+	server := newTestServer(t)
+	server.SetLatency("/login", 100*time.Millisecond)
+	client := newTestClient(t, server)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetPortfolioSummary()
+		done <- err
+	}()
+
+	for server.RequestCount("/login") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the login in flight was made with the old credentials
+	newCredentials := goksei.NewStaticCredentialProvider(kseitest.HashPassword("new-plain-password"))
+	if err := client.SetCredentials(testUsername, newCredentials); err != nil {
+		t.Fatalf("SetCredentials() error = %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	if _, err := client.Session(); !errors.Is(err, goksei.ErrNoSession) {
+		t.Errorf("Session() error = %v, want %v", err, goksei.ErrNoSession)
+	}
+}
+
+func TestClient_concurrentReconfiguration(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.GetPortfolioSummary(); err != nil {
+				t.Errorf("GetPortfolioSummary() error = %v", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			client.SetTimeout(time.Minute)
+			client.SetBaseURL(server.URL)
+			client.SetPlainPassword(false)
+			client.SetAuth(testUsername, kseitest.HashPassword(testPassword))
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestClient_Logout(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Logout(); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	if _, err := client.Session(); !errors.Is(err, goksei.ErrNoSession) {
+		t.Errorf("Logout() kept the cached token")
+	}
+
+	if got := server.RequestCount("/logout"); got != 1 {
+		t.Errorf("logout count = %v, want %v", got, 1)
+	}
+
+	// without a cached token there is no session to end
+	if err := client.Logout(); err != nil {
+		t.Fatalf("second Logout() error = %v", err)
+	}
+
+	if got := server.RequestCount("/logout"); got != 1 {
+		t.Errorf("logout count after second Logout() = %v, want %v", got, 1)
+	}
+}
+
+func TestClient_ClearToken(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.ClearToken(); err != nil {
+		t.Fatalf("ClearToken() error = %v", err)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.LoginCount(); got != 2 {
+		t.Errorf("login count = %v, want %v", got, 2)
+	}
+}
+
+func TestClient_Session(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	if _, err := client.Session(); !errors.Is(err, goksei.ErrNoSession) {
+		t.Fatalf("Session() before login error = %v, want %v", err, goksei.ErrNoSession)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := client.Session()
+	if err != nil {
+		t.Fatalf("Session() error = %v", err)
+	}
+
+	if session.Username != testUsername || session.Claims["jti"] != "1" {
+		t.Errorf("Session() = %+v, want the session of the first login", session)
+	}
+
+	if remaining := session.Remaining(); remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("Session().Remaining() = %v, want about an hour", remaining)
+	}
+}
+
+func TestClient_login_errorPayloads(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{"empty validation", http.StatusOK, "", `{"validation":""}`, goksei.ErrInvalidCredentials},
+		{"wrong password", http.StatusOK, "", `{"code":"400","status":"failed","message":"Username atau password salah"}`, goksei.ErrInvalidCredentials},
+		{"locked", http.StatusOK, "", `{"code":"403","status":"failed","message":"Akun Anda terkunci"}`, goksei.ErrAccountLocked},
+		{"password change", http.StatusBadRequest, "", `{"code":"400","status":"failed","message":"Silakan ganti password Anda"}`, goksei.ErrPasswordChangeRequired},
+		{"unauthorized without body", http.StatusUnauthorized, "", ``, goksei.ErrInvalidCredentials},
+		{"html rejection", http.StatusForbidden, "text/html", `<html>Forbidden</html>`, goksei.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.Fail(kseitest.Failure{Path: "/login", StatusCode: tt.status, ContentType: tt.contentType, Body: tt.body})
+
+			client := newTestClient(t, server)
+
+			_, err := client.GetPortfolioSummary()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			var loginErr *goksei.LoginError
+			if !errors.As(err, &loginErr) {
+				t.Fatalf("error = %v, want *LoginError", err)
+			}
+
+			if session, _ := client.Session(); session != nil {
+				t.Errorf("session stored after failed login: %+v", session)
+			}
+		})
+	}
+}
+
+func TestClient_Get_maintenancePage(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	// log in before the server goes down for maintenance
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Fail(kseitest.Failure{
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html",
+		Body:        `<html><body>Sedang dalam pemeliharaan</body></html>`,
+	})
+
+	_, err := client.GetCashBalances()
+	if !errors.Is(err, goksei.ErrMaintenance) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrMaintenance)
+	}
+}
+
+func TestClient_Get_responseTooLarge(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetMaxResponseSize(16)
+
+	_, err := client.GetPortfolioSummary()
+	if !errors.Is(err, goksei.ErrResponseTooLarge) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrResponseTooLarge)
+	}
+
+	client.SetMaxResponseSize(0)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Errorf("error = %v after restoring the default size", err)
+	}
+}
+
+func TestClient_Get_loginRejectedOnce(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := newTestServer(t)
+			server.Fail(kseitest.Failure{
+				StatusCode: status,
+				Body:       `{"code":"401","status":"failed","message":"Username atau password salah"}`,
+			})
+
+			client := newTestClient(t, server)
+
+			_, err := client.GetPortfolioSummary()
+			if !errors.Is(err, goksei.ErrInvalidCredentials) {
+				t.Fatalf("error = %v, want %v", err, goksei.ErrInvalidCredentials)
+			}
+
+			if got := server.RequestCount("/login"); got != 1 {
+				t.Errorf("login requests = %v, want 1", got)
+			}
+		})
+	}
+}
+
+// waitForWaiters blocks until n callers wait for the shared request of client to path.
+func waitForWaiters(t *testing.T, client *goksei.Client, path string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		waiters := client.Waiters(path)
+		if waiters == n {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters of %q = %v, want %v", path, waiters, n)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClient_GetContext_waiterCancelled(t *testing.T) {
+	server := newTestServer(t)
+	server.SetLatency("/login", 200*time.Millisecond)
+	client := newTestClient(t, server)
+
+	path := "/myportofolio/summary"
+
+	// the caller starting the shared request leaves first
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	first := make(chan error, 1)
+	go func() {
+		var dst map[string]any
+		first <- client.GetContext(ctx, path, &dst)
+	}()
+	waitForWaiters(t, client, path, 1)
+
+	second := make(chan error, 1)
+	go func() {
+		var dst map[string]any
+		second <- client.GetContext(t.Context(), path, &dst)
+	}()
+	waitForWaiters(t, client, path, 2)
+
+	cancel()
+
+	select {
+	case err := <-first:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled GetContext() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("cancelled GetContext() did not return before the login finished")
+	}
+
+	if err := <-second; err != nil {
+		t.Errorf("remaining GetContext() error = %v", err)
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+func TestClient_GetContext_allWaitersCancelled(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	// the first login hangs until the client gives up on it
+	var hung atomic.Bool
+
+	aborted := make(chan struct{})
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" && hung.CompareAndSwap(false, true) {
+			// the server only notices a closed connection once the body has been read
+			_, _ = io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			close(aborted)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+
+	path := "/myportofolio/summary"
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			var dst map[string]any
+			errs <- client.GetContext(ctx, path, &dst)
+		}()
+	}
+	waitForWaiters(t, client, path, 2)
+
+	cancel()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("GetContext() error = %v, want %v", err, context.Canceled)
+		}
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("login request was not aborted after all callers left")
+	}
+
+	// a later caller starts a new request instead of joining the aborted one
+	var dst map[string]any
+	if err := client.GetContext(t.Context(), path, &dst); err != nil {
+		t.Errorf("GetContext() after abort error = %v", err)
+	}
+}
+
+// countingTransport counts the requests per path sent through it.
+type countingTransport struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.paths == nil {
+		c.paths = map[string]int{}
+	}
+	c.paths[req.URL.Path]++
+	c.mu.Unlock()
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (c *countingTransport) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.paths[path]
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	server := newTestServer(t)
+	transport := &countingTransport{}
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username:      testUsername,
+		Password:      testPassword,
+		PlainPassword: true,
+		HTTPClient:    &http.Client{Transport: transport},
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	for _, path := range []string{"/activation/generated", "/login", "/myportofolio/summary"} {
+		if got := transport.count(path); got != 1 {
+			t.Errorf("requests to %s through the injected client = %v, want %v", path, got, 1)
+		}
+	}
+}
+
+func TestClient_HTTPClient_timeout(t *testing.T) {
+	const loginLatency = 200 * time.Millisecond
+
+	server := newTestServer(t)
+	server.SetLatency("/login", loginLatency)
+	transport := &countingTransport{}
+
+	// the per-request Timeout applies even though the injected client allows much longer
+	client := server.NewClient(goksei.ClientOpts{
+		Username:   testUsername,
+		Password:   kseitest.HashPassword(testPassword),
+		Timeout:    50 * time.Millisecond,
+		HTTPClient: &http.Client{Transport: transport, Timeout: time.Minute},
+	})
+
+	start := time.Now()
+
+	if _, err := client.GetPortfolioSummary(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetPortfolioSummary() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(start); elapsed >= loginLatency {
+		t.Errorf("GetPortfolioSummary() took %v, want less than %v", elapsed, loginLatency)
+	}
+
+	if got := transport.count("/login"); got != 1 {
+		t.Errorf("requests to /login through the injected client = %v, want %v", got, 1)
+	}
+}
@@ -0,0 +1,113 @@
+package goksei
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CredentialProvider supplies the password used to log in as username.
+// The client only calls it when a login is actually needed, so secrets are not
+// held in memory longer than necessary and can be rotated without restarting.
+type CredentialProvider interface {
+	Password(ctx context.Context, username string) (string, error)
+}
+
+// CredentialProviderFunc adapts an ordinary function to a CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context, username string) (string, error)
+
+// Password calls f(ctx, username).
+func (f CredentialProviderFunc) Password(ctx context.Context, username string) (string, error) {
+	return f(ctx, username)
+}
+
+// NewStaticCredentialProvider returns a CredentialProvider always supplying password.
+func NewStaticCredentialProvider(password string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context, string) (string, error) {
+		return password, nil
+	})
+}
+
+// NewEnvCredentialProvider returns a CredentialProvider reading the password
+// from the environment variable name at login time.
+func NewEnvCredentialProvider(name string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context, string) (string, error) {
+		password, ok := os.LookupEnv(name)
+		if !ok || password == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return password, nil
+	})
+}
+
+// NewFileCredentialProvider returns a CredentialProvider reading the password from the file
+// at path at login time, ignoring a trailing newline. The file must not be accessible by
+// group or others.
+func NewFileCredentialProvider(path string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context, string) (string, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode().Perm()&0o077 != 0 {
+			return "", fmt.Errorf("password file %s is accessible by other users (mode %s)", path, info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// NewCommandCredentialProvider returns a CredentialProvider running an external command
+// at login time, in the spirit of git credential helpers. The command receives
+// "username=<username>" followed by a blank line on stdin and the username in the
+// GOKSEI_USERNAME environment variable. It prints either a "password=<password>" line,
+// as git credential helpers do, or just the password on the first line.
+func NewCommandCredentialProvider(name string, args ...string) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context, username string) (string, error) {
+		var stdout, stderr bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = append(os.Environ(), "GOKSEI_USERNAME="+username)
+		cmd.Stdin = strings.NewReader("username=" + username + "\n\n")
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("error running credential command %s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		}
+
+		password := parseCommandPassword(stdout.String())
+		if password == "" {
+			return "", fmt.Errorf("credential command %s returned no password", name)
+		}
+
+		return password, nil
+	})
+}
+
+func parseCommandPassword(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+
+	for _, line := range lines {
+		if password, ok := strings.CutPrefix(line, "password="); ok {
+			return password
+		}
+	}
+
+	// git credential helper output without a password
+	if strings.HasPrefix(lines[0], "username=") {
+		return ""
+	}
+
+	return lines[0]
+}
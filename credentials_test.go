@@ -0,0 +1,124 @@
+package goksei_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestCredentialProviders(t *testing.T) {
+	dir := t.TempDir()
+
+	privateFile := filepath.Join(dir, "private")
+	if err := os.WriteFile(privateFile, []byte("file-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	publicFile := filepath.Join(dir, "public")
+	if err := os.WriteFile(publicFile, []byte("file-password\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOKSEI_TEST_PASSWORD", "env-password")
+
+	tests := []struct {
+		name     string
+		provider goksei.CredentialProvider
+		want     string
+		wantErr  bool
+	}{
+		{name: "static", provider: goksei.NewStaticCredentialProvider("static-password"), want: "static-password"},
+		{name: "env", provider: goksei.NewEnvCredentialProvider("GOKSEI_TEST_PASSWORD"), want: "env-password"},
+		{name: "env_missing", provider: goksei.NewEnvCredentialProvider("GOKSEI_TEST_MISSING"), wantErr: true},
+		{name: "file", provider: goksei.NewFileCredentialProvider(privateFile), want: "file-password"},
+		{name: "file_world_readable", provider: goksei.NewFileCredentialProvider(publicFile), wantErr: true},
+		{
+			name:     "command_plain",
+			provider: goksei.NewCommandCredentialProvider("sh", "-c", `echo "pass-for-$GOKSEI_USERNAME"`),
+			want:     "pass-for-alice",
+		},
+		{
+			name:     "command_git_style",
+			provider: goksei.NewCommandCredentialProvider("sh", "-c", `read line; echo "$line"; echo password=git-password`),
+			want:     "git-password",
+		},
+		{name: "command_failing", provider: goksei.NewCommandCredentialProvider("sh", "-c", "exit 1"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.Password(context.Background(), "alice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Password() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Password() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_credentialsFetchedLazily(t *testing.T) {
+	server := newTestServer(t)
+
+	calls := 0
+	client := server.NewClient(goksei.ClientOpts{
+		Username: testUsername,
+		Credentials: goksei.CredentialProviderFunc(func(context.Context, string) (string, error) {
+			calls++
+
+			return kseitest.HashPassword(testPassword), nil
+		}),
+	})
+
+	if calls != 0 {
+		t.Fatalf("provider called %d times before any request", calls)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetPortfolioSummary(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("provider called %d times, want %d", calls, 1)
+	}
+}
+
+func TestClient_credentialsTimeout(t *testing.T) {
+	server := newTestServer(t)
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username: testUsername,
+		Timeout:  50 * time.Millisecond,
+		// a hung credential helper only returns once its ctx is done
+		Credentials: goksei.CredentialProviderFunc(func(ctx context.Context, _ string) (string, error) {
+			<-ctx.Done()
+
+			return "", ctx.Err()
+		}),
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetPortfolioSummary()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("GetPortfolioSummary() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetPortfolioSummary() blocked on the credential provider")
+	}
+}
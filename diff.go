@@ -0,0 +1,233 @@
+package goksei
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ChangeKind identifies what changed between two snapshots.
+type ChangeKind string
+
+// Kinds of changes reported by Diff.
+const (
+	// PositionOpened is reported for a symbol held in the newer snapshot only, e.g. after a buy or an IPO allotment.
+	PositionOpened ChangeKind = "position_opened"
+
+	// PositionClosed is reported for a symbol held in the older snapshot only, e.g. after selling everything.
+	PositionClosed ChangeKind = "position_closed"
+
+	// QuantityChanged is reported when the units of a symbol changed, e.g. after a partial buy or sell,
+	// a stock split or a bonus share.
+	QuantityChanged ChangeKind = "quantity_changed"
+
+	// PriceChanged is reported when the closing price of a symbol held in both snapshots changed.
+	PriceChanged ChangeKind = "price_changed"
+
+	// CashChanged is reported when the balance of a cash account changed, including accounts
+	// appearing or disappearing.
+	CashChanged ChangeKind = "cash_changed"
+)
+
+// Change is a single difference between two snapshots of the same account.
+// Amounts are units for positions and the balance for cash.
+type Change struct {
+	Kind     ChangeKind    `json:"kind"`
+	Type     PortfolioType `json:"type"`             // CashType for CashChanged
+	Account  string        `json:"account"`          // ShareBalance.Account or CashBalance.AccountNumber
+	Symbol   string        `json:"symbol,omitempty"` // empty for CashChanged
+	Currency string        `json:"currency"`
+
+	OldAmount float64 `json:"oldAmount"`
+	NewAmount float64 `json:"newAmount"`
+	OldPrice  float64 `json:"oldPrice,omitempty"`
+	NewPrice  float64 `json:"newPrice,omitempty"`
+
+	From time.Time `json:"from"` // time of the older snapshot
+	To   time.Time `json:"to"`   // time of the newer snapshot
+}
+
+// Delta returns the change in amount, or in price for PriceChanged.
+func (c Change) Delta() float64 {
+	if c.Kind == PriceChanged {
+		return c.NewPrice - c.OldPrice
+	}
+
+	return c.NewAmount - c.OldAmount
+}
+
+// String describes the change in a short sentence, e.g. for notifications.
+func (c Change) String() string {
+	switch c.Kind {
+	case PositionOpened:
+		return fmt.Sprintf("%s: opened %s position of %g units at %g %s", c.Account, c.Symbol, c.NewAmount, c.NewPrice, c.Currency)
+	case PositionClosed:
+		return fmt.Sprintf("%s: closed %s position of %g units", c.Account, c.Symbol, c.OldAmount)
+	case QuantityChanged:
+		return fmt.Sprintf("%s: %s units changed from %g to %g (%+g)", c.Account, c.Symbol, c.OldAmount, c.NewAmount, c.Delta())
+	case PriceChanged:
+		return fmt.Sprintf("%s: %s price changed from %g to %g %s (%+g)", c.Account, c.Symbol, c.OldPrice, c.NewPrice, c.Currency, c.Delta())
+	case CashChanged:
+		return fmt.Sprintf("%s: cash balance changed from %g to %g %s (%+g)", c.Account, c.OldAmount, c.NewAmount, c.Currency, c.Delta())
+	}
+
+	return string(c.Kind)
+}
+
+// position is the holding of one symbol in one security account, summed over balance types.
+type position struct {
+	currency string
+	amount   float64
+	price    float64
+}
+
+type positionKey struct {
+	account string
+	symbol  string
+}
+
+type cashKey struct {
+	account  string
+	currency string
+}
+
+// Diff returns the changes between from and to, two snapshots of the same account, ordered by
+// portfolio type, account and symbol. A symbol whose units and price both changed yields
+// a QuantityChanged and a PriceChanged change.
+//
+// Portfolio types that failed to be fetched in either snapshot are skipped rather than
+// reported as closed positions.
+func Diff(from, to *Snapshot) []Change {
+	var changes []Change
+
+	for _, portfolioType := range ShareTypes {
+		if !diffable(from, to, portfolioType) {
+			continue
+		}
+
+		changes = append(changes, diffPositions(portfolioType, positions(from.Holdings[portfolioType]), positions(to.Holdings[portfolioType]))...)
+	}
+
+	if from.Errors[CashType] == nil && to.Errors[CashType] == nil {
+		changes = append(changes, diffCash(cashBalances(from.Cash), cashBalances(to.Cash))...)
+	}
+
+	for i := range changes {
+		changes[i].From = from.Time
+		changes[i].To = to.Time
+	}
+
+	return changes
+}
+
+func diffable(from, to *Snapshot, portfolioType PortfolioType) bool {
+	_, oldOK := from.Holdings[portfolioType]
+	_, newOK := to.Holdings[portfolioType]
+
+	return oldOK && newOK && from.Errors[portfolioType] == nil && to.Errors[portfolioType] == nil
+}
+
+func positions(balances []ShareBalance) map[positionKey]position {
+	result := make(map[positionKey]position, len(balances))
+
+	for _, balance := range balances {
+		key := positionKey{account: balance.Account, symbol: balance.Symbol()}
+
+		p := result[key]
+		p.currency = balance.Currency
+		p.amount += balance.Amount
+		p.price = balance.ClosingPrice
+		result[key] = p
+	}
+
+	return result
+}
+
+func diffPositions(portfolioType PortfolioType, from, to map[positionKey]position) []Change {
+	var changes []Change
+
+	for key, o := range from {
+		change := Change{Type: portfolioType, Account: key.account, Symbol: key.symbol, Currency: o.currency, OldAmount: o.amount, OldPrice: o.price}
+
+		n, ok := to[key]
+		if !ok {
+			change.Kind = PositionClosed
+			changes = append(changes, change)
+
+			continue
+		}
+
+		change.NewAmount, change.NewPrice = n.amount, n.price
+
+		if n.amount != o.amount {
+			change.Kind = QuantityChanged
+			changes = append(changes, change)
+		}
+
+		if n.price != o.price {
+			change.Kind = PriceChanged
+			changes = append(changes, change)
+		}
+	}
+
+	for key, n := range to {
+		if _, ok := from[key]; ok {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Kind:      PositionOpened,
+			Type:      portfolioType,
+			Account:   key.account,
+			Symbol:    key.symbol,
+			Currency:  n.currency,
+			NewAmount: n.amount,
+			NewPrice:  n.price,
+		})
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		return cmp.Or(
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Symbol, b.Symbol),
+			cmp.Compare(a.Kind, b.Kind),
+		)
+	})
+
+	return changes
+}
+
+// cashBalances sums balances in the currency of each account, so a foreign currency account
+// does not change just because its value in IDR moved with the exchange rate.
+func cashBalances(balances []CashBalance) map[cashKey]float64 {
+	result := make(map[cashKey]float64, len(balances))
+
+	for _, balance := range balances {
+		result[cashKey{account: balance.AccountNumber, currency: balance.Currency}] += balance.Balance
+	}
+
+	return result
+}
+
+func diffCash(from, to map[cashKey]float64) []Change {
+	var changes []Change
+
+	for key, o := range from {
+		if n := to[key]; n != o {
+			changes = append(changes, Change{Kind: CashChanged, Type: CashType, Account: key.account, Currency: key.currency, OldAmount: o, NewAmount: n})
+		}
+	}
+
+	for key, n := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, Change{Kind: CashChanged, Type: CashType, Account: key.account, Currency: key.currency, NewAmount: n})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		return cmp.Or(cmp.Compare(a.Account, b.Account), cmp.Compare(a.Currency, b.Currency))
+	})
+
+	return changes
+}
@@ -0,0 +1,104 @@
+package goksei_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+)
+
+func TestDiff(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	from := &goksei.Snapshot{
+		Time: start,
+		Cash: []goksei.CashBalance{
+			{AccountNumber: "111", Currency: "IDR", Balance: 1_000, BalanceIDR: 1_000},
+			{AccountNumber: "222", Currency: "USD", Balance: 10, BalanceIDR: 160_000},
+		},
+		Holdings: map[goksei.PortfolioType][]goksei.ShareBalance{
+			goksei.EquityType: {
+				{Account: "XL001", FullName: "BBCA - BANK CENTRAL ASIA Tbk", Currency: "IDR", Amount: 100, ClosingPrice: 9_000},
+				{Account: "XL001", FullName: "GOTO - GOTO GOJEK TOKOPEDIA Tbk", Currency: "IDR", Amount: 1_000, ClosingPrice: 70},
+				{Account: "XL001", FullName: "TLKM - TELKOM INDONESIA Tbk", Currency: "IDR", Amount: 50, ClosingPrice: 3_000},
+			},
+			goksei.MutualFundType: {},
+		},
+	}
+
+	to := &goksei.Snapshot{
+		Time: start.Add(24 * time.Hour),
+		Cash: []goksei.CashBalance{
+			{AccountNumber: "111", Currency: "IDR", Balance: 500, BalanceIDR: 500},
+			// only the exchange rate moved, the USD balance is unchanged
+			{AccountNumber: "222", Currency: "USD", Balance: 10, BalanceIDR: 165_000},
+		},
+		Holdings: map[goksei.PortfolioType][]goksei.ShareBalance{
+			goksei.EquityType: {
+				// price moved only
+				{Account: "XL001", FullName: "BBCA - BANK CENTRAL ASIA Tbk", Currency: "IDR", Amount: 100, ClosingPrice: 9_100},
+				// split across balance types, units and price moved
+				{Account: "XL001", FullName: "GOTO - GOTO GOJEK TOKOPEDIA Tbk", Currency: "IDR", BalanceType: "available", Amount: 1_500, ClosingPrice: 68},
+				{Account: "XL001", FullName: "GOTO - GOTO GOJEK TOKOPEDIA Tbk", Currency: "IDR", BalanceType: "blocked", Amount: 500, ClosingPrice: 68},
+			},
+			goksei.MutualFundType: {
+				{Account: "RD001", FullName: "RDPU - REKSA DANA PASAR UANG", Currency: "IDR", Amount: 10, ClosingPrice: 1_000},
+			},
+		},
+		// bonds failed to be fetched in the newer snapshot only, so they are not compared
+		Errors: map[goksei.PortfolioType]error{goksei.BondType: goksei.ErrServerUnavailable},
+	}
+	from.Holdings[goksei.BondType] = []goksei.ShareBalance{{Account: "XL001", FullName: "FR0100 - OBLIGASI NEGARA", Amount: 1, ClosingPrice: 100}}
+
+	type change struct {
+		kind          goksei.ChangeKind
+		symbol        string
+		before, after float64
+	}
+
+	want := []change{
+		{goksei.PriceChanged, "BBCA", 9_000, 9_100},
+		{goksei.PriceChanged, "GOTO", 70, 68},
+		{goksei.QuantityChanged, "GOTO", 1_000, 2_000},
+		{goksei.PositionClosed, "TLKM", 50, 0},
+		{goksei.PositionOpened, "RDPU", 0, 10},
+		{goksei.CashChanged, "", 1_000, 500},
+	}
+
+	changes := goksei.Diff(from, to)
+
+	got := make([]change, 0, len(changes))
+	for _, c := range changes {
+		before, after := c.OldAmount, c.NewAmount
+		if c.Kind == goksei.PriceChanged {
+			before, after = c.OldPrice, c.NewPrice
+		}
+
+		got = append(got, change{c.Kind, c.Symbol, before, after})
+
+		if !c.From.Equal(from.Time) || !c.To.Equal(to.Time) {
+			t.Errorf("%v: times = %v, %v", c, c.From, c.To)
+		}
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("Diff() =\n%v\nwant\n%v", got, want)
+	}
+
+	if delta := changes[2].Delta(); delta != 1_000 {
+		t.Errorf("QuantityChanged Delta() = %v, want 1000", delta)
+	}
+
+	if s := changes[5].String(); s != "111: cash balance changed from 1000 to 500 IDR (-500)" {
+		t.Errorf("CashChanged String() = %q", s)
+	}
+}
+
+func TestDiff_identical(t *testing.T) {
+	snapshot := newTestSnapshot("alice", time.Now(), 1_000)
+
+	if changes := goksei.Diff(snapshot, snapshot); len(changes) != 0 {
+		t.Errorf("Diff() = %v, want no changes", changes)
+	}
+}
@@ -0,0 +1,211 @@
+package goksei
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the client. Use errors.Is to check for them and errors.As
+// with *ResponseError to inspect the HTTP status and body of a failed response.
+var (
+	// ErrInvalidCredentials is returned when KSEI rejects the username or password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrOTPRequired is returned when KSEI asks for a one-time password at login
+	// but the client has no OTPProvider.
+	ErrOTPRequired = errors.New("one-time password required")
+
+	// ErrInvalidOTP is returned when KSEI rejects the one-time password.
+	ErrInvalidOTP = errors.New("invalid one-time password")
+
+	// ErrUnauthorized is returned when KSEI rejects the bearer token (HTTP 401 or 403).
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrRateLimited is returned when KSEI throttles the client (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrServerUnavailable is returned when KSEI responds with a 5xx status.
+	ErrServerUnavailable = errors.New("server unavailable")
+
+	// ErrUnexpectedResponse is returned for any other non-2xx status or a response
+	// whose payload does not have the expected shape.
+	ErrUnexpectedResponse = errors.New("unexpected response")
+
+	// ErrMaintenance is returned when KSEI answers with a web page instead of JSON,
+	// typically its maintenance notice served with a 200 status.
+	ErrMaintenance = errors.New("service under maintenance")
+
+	// ErrResponseTooLarge is returned when a response body exceeds the client's MaxResponseSize.
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// maxErrorBodyLength limits how much of a failed response body is kept in ResponseError.
+const maxErrorBodyLength = 512
+
+// isMaintenancePage reports whether a successful response carries a web page rather than
+// JSON. Test servers often label JSON as text/plain, so only markup content types and
+// bodies starting with a tag are considered pages.
+func isMaintenancePage(header http.Header, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+		switch {
+		case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+			return false
+		case mediaType == "text/html", mediaType == "application/xhtml+xml", strings.HasSuffix(mediaType, "/xml"):
+			return true
+		}
+	}
+
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("<"))
+}
+
+// newMaintenanceError describes a maintenance page, keeping the start of its body for context.
+func newMaintenanceError(header http.Header, body []byte) error {
+	if len(body) > maxErrorBodyLength {
+		body = append(body[:maxErrorBodyLength:maxErrorBodyLength], "..."...)
+	}
+
+	return fmt.Errorf("%w: got %q content: %s", ErrMaintenance, header.Get("Content-Type"), body)
+}
+
+// ResponseError describes a non-2xx response from KSEI.
+// It wraps one of ErrUnauthorized, ErrRateLimited, ErrServerUnavailable or ErrUnexpectedResponse.
+type ResponseError struct {
+	StatusCode int
+	Body       string // response body, truncated to a few hundred bytes
+
+	err error
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: status %d", e.err, e.StatusCode)
+	}
+
+	return fmt.Sprintf("%s: status %d: %s", e.err, e.StatusCode, e.Body)
+}
+
+// Unwrap returns the sentinel error matching the status code.
+func (e *ResponseError) Unwrap() error {
+	return e.err
+}
+
+func newResponseError(statusCode int, body []byte) *ResponseError {
+	if len(body) > maxErrorBodyLength {
+		body = append(body[:maxErrorBodyLength:maxErrorBodyLength], "..."...)
+	}
+
+	return &ResponseError{
+		StatusCode: statusCode,
+		Body:       string(body),
+		err:        statusError(statusCode),
+	}
+}
+
+func statusError(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= 500:
+		return ErrServerUnavailable
+	}
+
+	return ErrUnexpectedResponse
+}
+
+// Errors describing why KSEI rejected a login, wrapped in a *LoginError.
+var (
+	// ErrAccountLocked is returned when the account is locked, e.g. after too many failed logins.
+	ErrAccountLocked = errors.New("account locked")
+
+	// ErrPasswordChangeRequired is returned when KSEI requires the password to be changed
+	// on the website before logging in again.
+	ErrPasswordChangeRequired = errors.New("password change required")
+)
+
+// LoginError describes a login rejected by KSEI. It wraps one of ErrInvalidCredentials,
+// ErrAccountLocked or ErrPasswordChangeRequired, and the *ResponseError if the login
+// was rejected with an HTTP error status.
+type LoginError struct {
+	Code    string
+	Status  string
+	Message string
+
+	err   error
+	cause error
+}
+
+// Error implements the error interface.
+func (e *LoginError) Error() string {
+	if e.Message == "" {
+		return e.err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", e.err, e.Message)
+}
+
+// Unwrap returns the sentinel error describing the failure and its cause, if any.
+func (e *LoginError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.err}
+	}
+
+	return []error{e.err, e.cause}
+}
+
+// loginErrorKeywords maps lowercase fragments of KSEI messages to the failure they describe.
+// Messages are usually in Indonesian as the client logs in with lang=id.
+var loginErrorKeywords = []struct {
+	keyword string
+	err     error
+}{
+	{"terkunci", ErrAccountLocked},
+	{"diblokir", ErrAccountLocked},
+	{"locked", ErrAccountLocked},
+	{"ganti password", ErrPasswordChangeRequired},
+	{"ubah password", ErrPasswordChangeRequired},
+	{"ganti kata sandi", ErrPasswordChangeRequired},
+	{"ubah kata sandi", ErrPasswordChangeRequired},
+	{"password kedaluwarsa", ErrPasswordChangeRequired},
+	{"change password", ErrPasswordChangeRequired},
+	{"change your password", ErrPasswordChangeRequired},
+	{"password expired", ErrPasswordChangeRequired},
+}
+
+func newLoginError(res LoginResponse, cause error) *LoginError {
+	err := ErrInvalidCredentials
+
+	message := strings.ToLower(res.Message)
+	for _, k := range loginErrorKeywords {
+		if strings.Contains(message, k.keyword) {
+			err = k.err
+
+			break
+		}
+	}
+
+	return &LoginError{
+		Code:    res.Code,
+		Status:  res.Status,
+		Message: res.Message,
+		err:     err,
+		cause:   cause,
+	}
+}
+
+// isLoginRejection reports whether err means KSEI refused the login itself,
+// as opposed to a transient or server-side failure.
+func isLoginRejection(err error) bool {
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+
+	return respErr.StatusCode >= 400 && respErr.StatusCode < 500 && respErr.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,71 @@
+package goksei
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_newResponseError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		want       error
+	}{
+		{name: "unauthorized", statusCode: 401, want: ErrUnauthorized},
+		{name: "forbidden", statusCode: 403, want: ErrUnauthorized},
+		{name: "too_many_requests", statusCode: 429, want: ErrRateLimited},
+		{name: "bad_gateway", statusCode: 502, want: ErrServerUnavailable},
+		{name: "not_found", statusCode: 404, want: ErrUnexpectedResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error = newResponseError(tt.statusCode, []byte("oops"))
+
+			if !errors.Is(err, tt.want) {
+				t.Errorf("newResponseError() = %v, want %v", err, tt.want)
+			}
+
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) || respErr.StatusCode != tt.statusCode {
+				t.Errorf("newResponseError() status = %v, want %v", respErr, tt.statusCode)
+			}
+		})
+	}
+}
+
+func Test_newResponseError_truncatesBody(t *testing.T) {
+	err := newResponseError(500, []byte(strings.Repeat("x", 2*maxErrorBodyLength)))
+
+	if got := len(err.Body); got != maxErrorBodyLength+3 {
+		t.Errorf("newResponseError() body length = %v, want %v", got, maxErrorBodyLength+3)
+	}
+}
+
+func Test_isMaintenancePage(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", body: `{"data":[]}`, want: false},
+		{name: "sniffed_json", contentType: "text/plain; charset=utf-8", body: `{"data":[]}`, want: false},
+		{name: "html", contentType: "text/html; charset=utf-8", body: `<html>Sedang dalam pemeliharaan</html>`, want: true},
+		{name: "untyped_html", contentType: "", body: "\n<!DOCTYPE html>", want: true},
+		{name: "json_starting_with_tag", contentType: "application/json", body: `<not json>`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Type", tt.contentType)
+
+			if got := isMaintenancePage(header, []byte(tt.body)); got != tt.want {
+				t.Errorf("isMaintenancePage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
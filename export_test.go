@@ -0,0 +1,49 @@
+package goksei
+
+// Internals used by the tests in package goksei_test. Those tests talk to a kseitest.Server,
+// and kseitest imports goksei, so they cannot live in package goksei itself.
+
+type EncryptedValue = encryptedValue
+
+const (
+	EncryptionKeyLength   = encryptionKeyLength
+	PasswordHashKeySuffix = passwordHashKeySuffix
+	TOTPDigits            = totpDigits
+)
+
+var (
+	NewSession = newSession
+	RebindSQL  = rebindSQL
+	RetryAfter = retryAfter
+	TOTPCode   = totpCode
+)
+
+func (c *Client) AuthStore() AuthStore {
+	return c.authStore
+}
+
+func (c *Client) CachedPasswordHash(password string) (string, error) {
+	return c.cachedPasswordHash(c.config().username, password)
+}
+
+func (c *Client) StorePasswordHash(password, hash string) error {
+	return c.storePasswordHash(c.config().username, password, hash)
+}
+
+// Waiters returns the number of callers waiting for the shared request to path.
+func (c *Client) Waiters(path string) int {
+	key := c.singleflightKey(c.config(), path)
+
+	c.sfGroup.mu.Lock()
+	defer c.sfGroup.mu.Unlock()
+
+	if call, ok := c.sfGroup.calls[key]; ok {
+		return call.waiters
+	}
+
+	return 0
+}
+
+func (s *Snapshot) ComputeTotals() {
+	s.computeTotals()
+}
@@ -0,0 +1,73 @@
+package goksei
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup deduplicates concurrent calls with the same key, like singleflight, but
+// cancels the shared call once every caller waiting for it has given up.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	cancel  context.CancelFunc
+	waiters int
+	done    chan struct{}
+
+	val any
+	err error
+}
+
+// Do runs fn once for all concurrent callers of key and returns its result.
+// fn gets a context that carries the values of the first caller's ctx and is cancelled
+// when all callers' contexts are done. Each caller returns as soon as its own ctx is done.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	call, ok := g.calls[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{cancel: cancel, done: make(chan struct{})}
+		g.calls[key] = call
+
+		go func() {
+			call.val, call.err = fn(flightCtx)
+
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+
+			cancel()
+			close(call.done)
+		}()
+	}
+
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// nobody is left to use the result, later callers start a new flight
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+
+		return nil, ctx.Err()
+	}
+}
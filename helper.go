@@ -7,7 +7,8 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func getExpireTime(rawToken string) (*time.Time, error) {
+// parseClaims returns the claims of a JWT without verifying its signature.
+func parseClaims(rawToken string) (jwt.MapClaims, error) {
 	token, _, err := jwt.NewParser().ParseUnverified(rawToken, jwt.MapClaims{})
 	if err != nil {
 		return nil, err
@@ -18,17 +19,65 @@ func getExpireTime(rawToken string) (*time.Time, error) {
 		return nil, fmt.Errorf("invalid jwt map claims")
 	}
 
-	exp, ok := claims["exp"]
+	return claims, nil
+}
+
+// claimTime reads a NumericDate claim such as exp or iat.
+func claimTime(claims jwt.MapClaims, name string) (*time.Time, error) {
+	value, ok := claims[name]
 	if !ok {
-		return nil, fmt.Errorf("cannot find exp claim in the token")
+		return nil, fmt.Errorf("cannot find %s claim in the token", name)
 	}
 
-	expUnix, ok := exp.(float64)
+	unix, ok := value.(float64)
 	if !ok {
-		return nil, fmt.Errorf("exp claim invalid")
+		return nil, fmt.Errorf("%s claim invalid", name)
 	}
 
-	t := time.Unix(int64(expUnix), 0)
+	t := time.Unix(int64(unix), 0)
 
 	return &t, nil
 }
+
+func getExpireTime(rawToken string) (*time.Time, error) {
+	claims, err := parseClaims(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return claimTime(claims, "exp")
+}
+
+// valueExpireTime returns the expiry of a value written to an AuthStore,
+// if it holds a Session or a JWT.
+func valueExpireTime(v any) (time.Time, bool) {
+	var rawToken string
+
+	switch v := v.(type) {
+	case Session:
+		return v.ExpiresAt, !v.ExpiresAt.IsZero()
+	case *Session:
+		if v == nil {
+			return time.Time{}, false
+		}
+
+		return v.ExpiresAt, !v.ExpiresAt.IsZero()
+	case string:
+		rawToken = v
+	case *string:
+		if v == nil {
+			return time.Time{}, false
+		}
+
+		rawToken = *v
+	default:
+		return time.Time{}, false
+	}
+
+	expire, err := getExpireTime(rawToken)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return *expire, true
+}
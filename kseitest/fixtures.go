@@ -0,0 +1,64 @@
+package kseitest
+
+import "github.com/chickenzord/goksei"
+
+// SampleAccount returns an account holding a little of everything: IDR cash at one bank,
+// two stocks and a mutual fund, with an identity matching username.
+func SampleAccount(username, password string) Account {
+	return Account{
+		Username: username,
+		Password: password,
+		Cash: []goksei.CashBalance{
+			{
+				ID:            1,
+				AccountNumber: "1234567890",
+				BankID:        "BCA",
+				Currency:      "IDR",
+				Balance:       1_500_000,
+				BalanceIDR:    1_500_000,
+				Status:        1,
+			},
+		},
+		Shares: map[goksei.PortfolioType][]goksei.ShareBalance{
+			goksei.EquityType: {
+				{
+					Account:      "XL001CANE000000",
+					FullName:     "BBCA - BANK CENTRAL ASIA Tbk",
+					Participant:  "MAHAKARYA ARTHA SEKURITAS, PT",
+					BalanceType:  "available",
+					Currency:     "IDR",
+					Amount:       500,
+					ClosingPrice: 9_000,
+				},
+				{
+					Account:      "XL001CANE000000",
+					FullName:     "GOTO - GOTO GOJEK TOKOPEDIA Tbk",
+					Participant:  "MAHAKARYA ARTHA SEKURITAS, PT",
+					BalanceType:  "available",
+					Currency:     "IDR",
+					Amount:       10_000,
+					ClosingPrice: 70,
+				},
+			},
+			goksei.MutualFundType: {
+				{
+					Account:      "RD001CANE000000",
+					FullName:     "RDPU - REKSA DANA PASAR UANG",
+					Participant:  "BIBIT",
+					BalanceType:  "available",
+					Currency:     "IDR",
+					Amount:       1_000.5,
+					ClosingPrice: 1_250,
+				},
+			},
+		},
+		Identity: goksei.GlobalIdentity{
+			LoginID:      username,
+			Username:     username,
+			Email:        username,
+			FullName:     "KSEI TEST",
+			InvestorID:   "IDD000000000000",
+			InvestorName: "KSEI TEST",
+		},
+	}
+}
@@ -0,0 +1,538 @@
+// Package kseitest provides an in-process fake of the KSEI service for hermetic tests
+// of code built on goksei.Client.
+//
+// The fake implements the endpoints used by the client: password hashing, login with an
+// optional one-time password, logout, the portfolio summary and details, and the global
+// identity. It issues real JWTs, serves configurable fixtures per account and can inject
+// failures, slow responses down or expire sessions on demand.
+//
+//	server := kseitest.NewServer()
+//	defer server.Close()
+//
+//	server.AddAccount(kseitest.SampleAccount("user@example.com", "secret"))
+//
+//	client := server.NewClient(goksei.ClientOpts{
+//		Username:      "user@example.com",
+//		Password:      "secret",
+//		PlainPassword: true,
+//	})
+package kseitest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultTokenTTL is the lifetime of the tokens issued by a Server unless changed with SetTokenTTL.
+const DefaultTokenTTL = time.Hour
+
+var signingKey = []byte("kseitest")
+
+// Account holds the credentials and fixtures of an account served by a Server.
+type Account struct {
+	Username string
+	Password string // plain password, see HashPassword for the form expected at login
+
+	Cash     []goksei.CashBalance
+	Shares   map[goksei.PortfolioType][]goksei.ShareBalance // keyed by EquityType, MutualFundType, ...
+	Identity goksei.GlobalIdentity
+
+	// Rates are the IDR prices of other currencies, e.g. {"USD": 16_000}, used to value
+	// holdings in those currencies in the portfolio summary as KSEI does. Holdings in a
+	// currency without a rate are summed at face value.
+	Rates map[string]float64
+
+	// OTP is the one-time password confirming each login, following the challenge flow
+	// assumed by goksei.LoginResponse. Empty logs in with the password alone.
+	OTP string
+}
+
+// Failure describes responses injected by a Server in place of the normal ones.
+type Failure struct {
+	Path        string // request path to fail, e.g. "/login"; empty fails every path
+	StatusCode  int    // default: 500
+	Body        string
+	ContentType string      // default: "application/json"
+	Header      http.Header // additional response headers, e.g. Retry-After
+	Times       int         // number of requests to fail; zero fails until ClearFailures
+}
+
+type session struct {
+	username  string
+	expiresAt time.Time
+}
+
+// Server is a fake KSEI service listening on a local address.
+// Point a client at it with SetBaseURL(server.URL), or use NewClient.
+// It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	accounts   map[string]Account
+	sessions   map[string]*session // keyed by token
+	challenges map[string]string   // usernames keyed by pending OTP token
+	failures   []*Failure
+	latencies  map[string]time.Duration
+	tokenTTL   time.Duration
+	logins     int
+	otpLogins  int // logins answered with an OTP challenge
+	requests   map[string]int
+}
+
+// NewServer starts and returns a new Server without any account.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		accounts:   make(map[string]Account),
+		sessions:   make(map[string]*session),
+		challenges: make(map[string]string),
+		latencies:  make(map[string]time.Duration),
+		tokenTTL:   DefaultTokenTTL,
+		requests:   make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /activation/generated", s.handleActivation)
+	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("POST /login/otp", s.handleLoginOTP)
+	mux.HandleFunc("POST /logout", s.handleLogout)
+	mux.HandleFunc("GET /myportofolio/summary", s.authorized(s.handleSummary))
+	mux.HandleFunc("GET /myportofolio/summary-detail/{type}", s.authorized(s.handleSummaryDetail))
+	mux.HandleFunc("GET /myaccount/global-identity/", s.authorized(s.handleGlobalIdentity))
+
+	s.Server = httptest.NewServer(s.intercept(mux))
+
+	return s
+}
+
+// NewClient creates a goksei.Client talking to the server. If opts has no AuthStore,
+// a fresh in-memory one is used.
+func (s *Server) NewClient(opts goksei.ClientOpts) *goksei.Client {
+	if opts.AuthStore == nil {
+		opts.AuthStore = goksei.NewMemoryAuthStore()
+	}
+
+	client := goksei.NewClient(opts)
+	client.SetBaseURL(s.URL)
+
+	return client
+}
+
+// AddAccount adds account to the server, replacing any account with the same username.
+func (s *Server) AddAccount(account Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts[account.Username] = account
+}
+
+// SetTokenTTL changes the lifetime of tokens issued from now on.
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenTTL = ttl
+}
+
+// ExpireSessions invalidates every issued token, as KSEI does when the same account
+// logs in elsewhere. Data endpoints answer 401 until the client logs in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sessions)
+}
+
+// Fail injects failure, replacing the normal responses of the matching requests.
+// Failures are checked in the order they were added.
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &failure)
+}
+
+// ClearFailures removes all injected failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = nil
+}
+
+// SetLatency delays the responses to path by latency, e.g. to test timeouts or requests
+// overlapping a login. An empty path delays every path without a latency of its own, and
+// zero removes the delay. A delayed request stops waiting once its client goes away.
+func (s *Server) SetLatency(path string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if latency == 0 {
+		delete(s.latencies, path)
+
+		return
+	}
+
+	s.latencies[path] = latency
+}
+
+// LoginCount returns the number of successful logins.
+func (s *Server) LoginCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// RequestCount returns the number of requests received for path, including failed ones.
+func (s *Server) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// HashPassword returns the hashed form of a plain password accepted by the server's login,
+// i.e. what a client with PlainPassword set obtains from the activation endpoint.
+func HashPassword(password string) string {
+	return hashSHA1(fmt.Sprintf("%x", sha1.Sum([]byte(password))))
+}
+
+func hashSHA1(passwordSHA1 string) string {
+	return "kseitest-" + passwordSHA1
+}
+
+// intercept counts requests, delays them and answers with injected failures.
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		latency, ok := s.latencies[r.URL.Path]
+		if !ok {
+			latency = s.latencies[""]
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			timer := time.NewTimer(latency)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		s.mu.Lock()
+		failure, ok := s.takeFailure(r.URL.Path)
+		s.mu.Unlock()
+
+		if !ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if failure.ContentType == "" {
+			failure.ContentType = "application/json"
+		}
+
+		if failure.StatusCode == 0 {
+			failure.StatusCode = http.StatusInternalServerError
+		}
+
+		for key, values := range failure.Header {
+			w.Header()[key] = values
+		}
+
+		w.Header().Set("Content-Type", failure.ContentType)
+		w.WriteHeader(failure.StatusCode)
+		fmt.Fprint(w, failure.Body)
+	})
+}
+
+// takeFailure returns the first failure matching path. The caller must hold s.mu.
+func (s *Server) takeFailure(path string) (Failure, bool) {
+	for i, failure := range s.failures {
+		if failure.Path != "" && failure.Path != path {
+			continue
+		}
+
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+
+		return *failure, true
+	}
+
+	return Failure{}, false
+}
+
+// authorized rejects requests without a valid bearer token and passes the account to next.
+func (s *Server) authorized(next func(http.ResponseWriter, *http.Request, Account)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		sess, ok := s.sessions[token]
+		if ok && !time.Now().Before(sess.expiresAt) {
+			delete(s.sessions, token)
+
+			ok = false
+		}
+
+		var account Account
+		if ok {
+			account, ok = s.accounts[sess.username]
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"code": "401", "status": "failed", "message": "Unauthorized"})
+
+			return
+		}
+
+		next(w, r, account)
+	}
+}
+
+func (s *Server) handleActivation(w http.ResponseWriter, r *http.Request) {
+	param, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("param"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "400", "status": "failed", "message": "invalid param"})
+
+		return
+	}
+
+	passwordSHA1, _, _ := strings.Cut(string(param), "@@!!@@")
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"code":   "200",
+		"status": "success",
+		"data":   []map[string]string{{"pass": hashSHA1(passwordSHA1)}},
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req goksei.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, goksei.LoginResponse{Code: "400", Status: "failed", Message: "invalid request"})
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[req.Username]
+	if !ok || req.Password != HashPassword(account.Password) {
+		writeJSON(w, http.StatusOK, goksei.LoginResponse{Code: "400", Status: "failed", Message: "Username atau password salah"})
+
+		return
+	}
+
+	if account.OTP != "" {
+		s.otpLogins++
+
+		otpToken := fmt.Sprintf("challenge-%d", s.otpLogins)
+		s.challenges[otpToken] = account.Username
+
+		writeJSON(w, http.StatusOK, goksei.LoginResponse{
+			Code:           "200",
+			Status:         "success",
+			OTPRequired:    true,
+			OTPToken:       otpToken,
+			OTPMethod:      "email",
+			OTPDestination: maskEmail(account.Username),
+		})
+
+		return
+	}
+
+	s.writeSession(w, account)
+}
+
+func (s *Server) handleLoginOTP(w http.ResponseWriter, r *http.Request) {
+	var req goksei.OTPVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, goksei.LoginResponse{Code: "400", Status: "failed", Message: "invalid request"})
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username, ok := s.challenges[req.OTPToken]
+
+	account := s.accounts[username]
+	if !ok || username != req.Username || req.OTP != account.OTP {
+		writeJSON(w, http.StatusOK, goksei.LoginResponse{Code: "400", Status: "failed", Message: "Kode OTP salah"})
+
+		return
+	}
+
+	delete(s.challenges, req.OTPToken)
+
+	s.writeSession(w, account)
+}
+
+// writeSession issues a token to account and writes it as a successful login.
+// The caller must hold s.mu.
+func (s *Server) writeSession(w http.ResponseWriter, account Account) {
+	s.logins++
+
+	now := time.Now()
+	expiresAt := now.Add(s.tokenTTL)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": account.Username,
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+		"jti": fmt.Sprint(s.logins),
+	}).SignedString(signingKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, goksei.LoginResponse{Code: "500", Status: "failed", Message: err.Error()})
+
+		return
+	}
+
+	s.sessions[token] = &session{username: account.Username, expiresAt: expiresAt}
+
+	writeJSON(w, http.StatusOK, goksei.LoginResponse{Code: "200", Status: "success", Validation: token})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"code": "200", "status": "success"})
+}
+
+func (s *Server) handleSummary(w http.ResponseWriter, _ *http.Request, account Account) {
+	amounts := map[goksei.PortfolioType]float64{}
+
+	for _, cash := range account.Cash {
+		amounts[goksei.CashType] += cash.CurrentBalance()
+	}
+
+	for portfolioType, shares := range account.Shares {
+		for _, share := range shares {
+			value := share.CurrentValue()
+			if rate, ok := account.Rates[share.Currency]; ok {
+				value *= rate
+			}
+
+			amounts[portfolioType] += value
+		}
+	}
+
+	var total float64
+	for _, amount := range amounts {
+		total += amount
+	}
+
+	res := goksei.PortfolioSummaryResponse{Total: total, Details: []goksei.PortfolioSummaryDetails{}}
+
+	for _, portfolioType := range portfolioTypes {
+		amount, ok := amounts[portfolioType]
+		if !ok {
+			continue
+		}
+
+		detail := goksei.PortfolioSummaryDetails{Type: string(portfolioType), Amount: amount}
+		if total != 0 {
+			detail.Percent = amount / total * 100
+		}
+
+		res.Details = append(res.Details, detail)
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleSummaryDetail(w http.ResponseWriter, r *http.Request, account Account) {
+	portfolioType := goksei.PortfolioType(strings.ToUpper(r.PathValue("type")))
+
+	if portfolioType == goksei.CashType {
+		cash := account.Cash
+		if cash == nil {
+			cash = []goksei.CashBalance{}
+		}
+
+		writeJSON(w, http.StatusOK, goksei.CashBalanceResponse{Data: cash})
+
+		return
+	}
+
+	if portfolioType.Name() == "unknown" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"code": "404", "status": "failed", "message": "Not Found"})
+
+		return
+	}
+
+	res := goksei.ShareBalanceResponse{Data: account.Shares[portfolioType]}
+	if res.Data == nil {
+		res.Data = []goksei.ShareBalance{}
+	}
+
+	for _, share := range res.Data {
+		res.Total += share.CurrentValue()
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleGlobalIdentity(w http.ResponseWriter, _ *http.Request, account Account) {
+	writeJSON(w, http.StatusOK, goksei.GlobalIdentityResponse{
+		Code:       "200",
+		Status:     "success",
+		Identities: []goksei.GlobalIdentity{account.Identity},
+	})
+}
+
+// portfolioTypes lists the portfolio types in the order KSEI reports them.
+var portfolioTypes = []goksei.PortfolioType{
+	goksei.EquityType,
+	goksei.MutualFundType,
+	goksei.BondType,
+	goksei.OtherType,
+	goksei.CashType,
+}
+
+// maskEmail hides most of the local part of an email address, as KSEI shows the
+// destination of a one-time password. Example: "us***@example.com".
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || len(local) <= 2 {
+		return "***@" + domain
+	}
+
+	return local[:2] + "***@" + domain
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,273 @@
+package kseitest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+const (
+	username = "user@example.com"
+	password = "secret"
+)
+
+func newTestClient(t *testing.T) (*kseitest.Server, *goksei.Client) {
+	t.Helper()
+
+	server := kseitest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddAccount(kseitest.SampleAccount(username, password))
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username:      username,
+		Password:      password,
+		PlainPassword: true,
+	})
+
+	return server, client
+}
+
+func TestServer_fixtures(t *testing.T) {
+	_, client := newTestClient(t)
+
+	summary, err := client.GetPortfolioSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1.5M cash + 500 BBCA @ 9000 + 10000 GOTO @ 70 + 1000.5 RDPU @ 1250
+	if want := 1_500_000 + 4_500_000 + 700_000 + 1_250_625.0; summary.Total != want {
+		t.Errorf("summary total = %v, want %v", summary.Total, want)
+	}
+
+	if got := len(summary.Details); got != 3 {
+		t.Errorf("summary details = %v, want 3 portfolio types", summary.Details)
+	}
+
+	cash, err := client.GetCashBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cash.Data) != 1 || cash.Data[0].CurrentBalance() != 1_500_000 {
+		t.Errorf("cash balances = %+v", cash.Data)
+	}
+
+	equity, err := client.GetShareBalances(goksei.EquityType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(equity.Data) != 2 || equity.Data[0].Symbol() != "BBCA" {
+		t.Errorf("equity balances = %+v", equity.Data)
+	}
+
+	bonds, err := client.GetShareBalances(goksei.BondType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bonds.Data) != 0 {
+		t.Errorf("bond balances = %+v, want none", bonds.Data)
+	}
+
+	identity, err := client.GetGlobalIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identity.Identities) != 1 || identity.Identities[0].Username != username {
+		t.Errorf("identities = %+v", identity.Identities)
+	}
+}
+
+func TestServer_hashedPassword(t *testing.T) {
+	server, _ := newTestClient(t)
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username: username,
+		Password: kseitest.HashPassword(password),
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.RequestCount("/activation/generated"); got != 0 {
+		t.Errorf("activation requests = %v, want 0", got)
+	}
+}
+
+func TestServer_invalidCredentials(t *testing.T) {
+	server, _ := newTestClient(t)
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username:      username,
+		Password:      "wrong",
+		PlainPassword: true,
+	})
+
+	_, err := client.GetPortfolioSummary()
+	if !errors.Is(err, goksei.ErrInvalidCredentials) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrInvalidCredentials)
+	}
+}
+
+func TestServer_ExpireSessions(t *testing.T) {
+	server, client := newTestClient(t)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.ExpireSessions()
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.LoginCount(); got != 2 {
+		t.Errorf("logins = %v, want 2", got)
+	}
+}
+
+func TestServer_SetTokenTTL(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetTokenTTL(2 * time.Minute)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := client.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if remaining := session.Remaining(); remaining > 2*time.Minute || remaining < time.Minute {
+		t.Errorf("session remaining = %v, want about 2m", remaining)
+	}
+}
+
+func TestServer_SetLatency(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetLatency("/login", 100*time.Millisecond)
+	client.SetTimeout(20 * time.Millisecond)
+
+	if _, err := client.GetPortfolioSummary(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	server.SetLatency("/login", 0)
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Errorf("error = %v after removing the latency", err)
+	}
+}
+
+func TestServer_otp(t *testing.T) {
+	server, _ := newTestClient(t)
+
+	account := kseitest.SampleAccount(username, password)
+	account.OTP = "123456"
+	server.AddAccount(account)
+
+	var challenges []goksei.OTPChallenge
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username:      username,
+		Password:      password,
+		PlainPassword: true,
+		OTPProvider: goksei.OTPProviderFunc(func(_ context.Context, challenge goksei.OTPChallenge) (string, error) {
+			challenges = append(challenges, challenge)
+
+			return "123456", nil
+		}),
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(challenges) != 1 || challenges[0].Destination != "us***@example.com" {
+		t.Errorf("OTP challenges = %+v, want one sent to the masked email", challenges)
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("logins = %v, want 1", got)
+	}
+}
+
+func TestServer_Fail(t *testing.T) {
+	server, client := newTestClient(t)
+
+	server.Fail(kseitest.Failure{Path: "/myportofolio/summary", StatusCode: http.StatusBadGateway, Times: 1})
+
+	if _, err := client.GetPortfolioSummary(); !errors.Is(err, goksei.ErrServerUnavailable) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrServerUnavailable)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Errorf("error = %v after the failure was used up", err)
+	}
+
+	server.Fail(kseitest.Failure{ContentType: "text/html", StatusCode: http.StatusOK, Body: "<html>maintenance</html>"})
+
+	if _, err := client.GetCashBalances(); !errors.Is(err, goksei.ErrMaintenance) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrMaintenance)
+	}
+
+	server.ClearFailures()
+
+	if _, err := client.GetCashBalances(); err != nil {
+		t.Errorf("error = %v after clearing failures", err)
+	}
+}
+
+func TestServer_snapshotReconciles(t *testing.T) {
+	_, client := newTestClient(t)
+
+	snapshot, err := client.GetSnapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot.Total != snapshot.Summary.Total {
+		t.Errorf("snapshot total = %v, summary total = %v", snapshot.Total, snapshot.Summary.Total)
+	}
+}
+
+func TestServer_snapshotForeignHoldings(t *testing.T) {
+	server, client := newTestClient(t)
+
+	account := kseitest.SampleAccount(username, password)
+	account.Shares[goksei.EquityType] = append(account.Shares[goksei.EquityType], goksei.ShareBalance{
+		Account:      "XL001",
+		FullName:     "AAPL - APPLE INC",
+		Currency:     "USD",
+		Amount:       2,
+		ClosingPrice: 200,
+	})
+	account.Rates = map[string]float64{"USD": 16_000}
+	server.AddAccount(account)
+
+	snapshot, err := client.GetSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("GetSnapshot() error = %v", err)
+	}
+
+	if got := snapshot.ForeignTotals["USD"]; got != 400 {
+		t.Errorf("foreign total = %v, want 400", got)
+	}
+
+	// the summary includes the holding at its IDR value, the snapshot total does not
+	if want := snapshot.Summary.Total - 2*200*16_000; snapshot.Total != want {
+		t.Errorf("snapshot total = %v, want %v", snapshot.Total, want)
+	}
+}
@@ -0,0 +1,120 @@
+package goksei
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OTPChallenge describes the additional verification step requested by KSEI at login.
+type OTPChallenge struct {
+	Username    string
+	Method      string // how the code is delivered. Example: "email", "sms" or "totp"
+	Destination string // masked destination of the code, if any
+}
+
+// OTPProvider supplies the one-time password answering a login challenge,
+// e.g. by prompting the user in an interactive CLI or by generating a TOTP code.
+type OTPProvider interface {
+	OTP(ctx context.Context, challenge OTPChallenge) (string, error)
+}
+
+// OTPProviderFunc adapts an ordinary function to an OTPProvider.
+type OTPProviderFunc func(ctx context.Context, challenge OTPChallenge) (string, error)
+
+// OTP calls f(ctx, challenge).
+func (f OTPProviderFunc) OTP(ctx context.Context, challenge OTPChallenge) (string, error) {
+	return f(ctx, challenge)
+}
+
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+)
+
+// NewTOTPProvider returns an OTPProvider generating RFC 6238 time-based codes
+// (HMAC-SHA1, 30 seconds, 6 digits) from a base32 encoded secret, as shown when
+// enrolling an authenticator app.
+func NewTOTPProvider(secret string) (OTPProvider, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	return OTPProviderFunc(func(context.Context, OTPChallenge) (string, error) {
+		return totpCode(key, time.Now(), totpDigits), nil
+	}), nil
+}
+
+func totpCode(key []byte, t time.Time, digits int) string {
+	var counter [8]byte
+
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod.Seconds())))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// verifyOTP completes a login challenge and returns the issued token.
+func (c *Client) verifyOTP(ctx context.Context, cfg clientConfig, challenge LoginResponse) (string, error) {
+	if cfg.otpProvider == nil {
+		return "", ErrOTPRequired
+	}
+
+	otp, err := cfg.otpProvider.OTP(ctx, OTPChallenge{
+		Username:    cfg.username,
+		Method:      challenge.OTPMethod,
+		Destination: challenge.OTPDestination,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting one-time password: %w", err)
+	}
+
+	res, err := c.postJSON(ctx, cfg, "/login/otp?lang=id", OTPVerificationRequest{
+		Username: cfg.username,
+		OTPToken: challenge.OTPToken,
+		OTP:      otp,
+	})
+	// not wrapped as ErrUnauthorized: a rejected code must not trigger another login
+	var respErr *ResponseError
+	if errors.As(err, &respErr) && errors.Is(err, ErrUnauthorized) {
+		return "", fmt.Errorf("%w: status %d", ErrInvalidOTP, respErr.StatusCode)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	var loginResponse LoginResponse
+
+	if err := json.Unmarshal(res.body, &loginResponse); err != nil {
+		return "", fmt.Errorf("%w: error decoding otp response body: %w", ErrUnexpectedResponse, err)
+	}
+
+	if loginResponse.Validation == "" {
+		return "", ErrInvalidOTP
+	}
+
+	return loginResponse.Validation, nil
+}
@@ -0,0 +1,130 @@
+package goksei_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func Test_totpCode(t *testing.T) {
+	// test vectors from RFC 6238 appendix B, SHA1 variant
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.unix), func(t *testing.T) {
+			if got := goksei.TOTPCode(key, time.Unix(tt.unix, 0), 8); got != tt.want {
+				t.Errorf("totpCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTOTPProvider(t *testing.T) {
+	if _, err := goksei.NewTOTPProvider("not base32!"); err == nil {
+		t.Errorf("NewTOTPProvider() accepted an invalid secret")
+	}
+
+	provider, err := goksei.NewTOTPProvider("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	if err != nil {
+		t.Fatalf("NewTOTPProvider() error = %v", err)
+	}
+
+	got, err := provider.OTP(context.Background(), goksei.OTPChallenge{})
+	if err != nil || len(got) != goksei.TOTPDigits {
+		t.Errorf("OTP() = %v, %v, want a %d digits code", got, err, goksei.TOTPDigits)
+	}
+}
+
+// newTestOTPServer starts a kseitest.Server whose test user confirms logins with otp.
+func newTestOTPServer(t *testing.T, otp string) *kseitest.Server {
+	t.Helper()
+
+	server := newTestServer(t)
+
+	account := kseitest.SampleAccount(testUsername, testPassword)
+	account.OTP = otp
+	server.AddAccount(account)
+
+	return server
+}
+
+func TestClient_loginOTPChallenge(t *testing.T) {
+	server := newTestOTPServer(t, "123456")
+
+	staticOTP := func(otp string) goksei.OTPProvider {
+		return goksei.OTPProviderFunc(func(_ context.Context, challenge goksei.OTPChallenge) (string, error) {
+			if challenge.Method != "email" || challenge.Destination != "us***@example.com" {
+				t.Errorf("OTP() challenge = %+v, want the email challenge", challenge)
+			}
+
+			return otp, nil
+		})
+	}
+
+	tests := []struct {
+		name        string
+		otpProvider goksei.OTPProvider
+		wantErr     error
+	}{
+		{name: "no_provider", otpProvider: nil, wantErr: goksei.ErrOTPRequired},
+		{name: "wrong_otp", otpProvider: staticOTP("000000"), wantErr: goksei.ErrInvalidOTP},
+		{name: "valid_otp", otpProvider: staticOTP("123456"), wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := server.NewClient(goksei.ClientOpts{
+				Username:    testUsername,
+				Password:    kseitest.HashPassword(testPassword),
+				OTPProvider: tt.otpProvider,
+			})
+
+			if _, err := client.GetPortfolioSummary(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetPortfolioSummary() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_loginOTPRejectedPromptsOnce(t *testing.T) {
+	server := newTestOTPServer(t, "123456")
+	server.Fail(kseitest.Failure{Path: "/login/otp", StatusCode: http.StatusUnauthorized})
+
+	var prompts atomic.Int32
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username: testUsername,
+		Password: kseitest.HashPassword(testPassword),
+		OTPProvider: goksei.OTPProviderFunc(func(context.Context, goksei.OTPChallenge) (string, error) {
+			prompts.Add(1)
+
+			return "000000", nil
+		}),
+	})
+
+	_, err := client.GetPortfolioSummary()
+	if !errors.Is(err, goksei.ErrInvalidOTP) || errors.Is(err, goksei.ErrUnauthorized) {
+		t.Errorf("GetPortfolioSummary() error = %v, want only %v", err, goksei.ErrInvalidOTP)
+	}
+
+	if got := prompts.Load(); got != 1 {
+		t.Errorf("OTP prompts = %v, want 1", got)
+	}
+}
@@ -0,0 +1,89 @@
+package goksei
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+)
+
+// passwordHashKeySuffix is appended to the username to form the AuthStore key of a cached password hash.
+const passwordHashKeySuffix = ".password-hash"
+
+// cachedPassword is what the client stores in the AuthStore when CachePasswordHash is enabled.
+type cachedPassword struct {
+	Fingerprint string `json:"fingerprint"` // detects a changed plain password
+	Hash        string `json:"hash"`
+}
+
+// HashPassword returns the hashed form of a plain password, as expected by KSEI's login endpoint
+// and accepted by ClientOpts.Password when PlainPassword is false.
+//
+// The hash is computed by KSEI's activation service from a SHA1 digest of the password,
+// it cannot be derived locally. Obtaining it once and storing it avoids sending the
+// digest to KSEI on every login, see also ClientOpts.CachePasswordHash.
+func (c *Client) HashPassword(ctx context.Context, plainPassword string) (string, error) {
+	return c.requestPasswordHash(ctx, c.config(), plainPassword)
+}
+
+func passwordFingerprint(username, password string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedPasswordHash returns the cached hash of password, or an empty string
+// if there is none or it was made for another password.
+func (c *Client) cachedPasswordHash(username, password string) (string, error) {
+	if c.authStore == nil {
+		return "", nil
+	}
+
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	var cached cachedPassword
+
+	found, err := c.authStore.Get(username+passwordHashKeySuffix, &cached)
+	if errors.Is(err, ErrDecryption) {
+		// treated as a miss, the hash is fetched again and overwrites it
+		return "", nil
+	}
+
+	if err != nil || !found {
+		return "", err
+	}
+
+	fingerprint := passwordFingerprint(username, password)
+	if subtle.ConstantTimeCompare([]byte(cached.Fingerprint), []byte(fingerprint)) != 1 {
+		return "", nil
+	}
+
+	return cached.Hash, nil
+}
+
+func (c *Client) storePasswordHash(username, password, hash string) error {
+	if c.authStore == nil {
+		return nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.authStore.Set(username+passwordHashKeySuffix, cachedPassword{
+		Fingerprint: passwordFingerprint(username, password),
+		Hash:        hash,
+	})
+}
+
+func (c *Client) purgePasswordHash(username string) error {
+	if c.authStore == nil {
+		return nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.authStore.Delete(username + passwordHashKeySuffix)
+}
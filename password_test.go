@@ -0,0 +1,154 @@
+package goksei_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func newTestCachingClient(t *testing.T, server *kseitest.Server) *goksei.Client {
+	t.Helper()
+
+	authStore, err := goksei.NewEncryptedAuthStore(goksei.NewMemoryAuthStore(), bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server.NewClient(goksei.ClientOpts{
+		AuthStore:         authStore,
+		Username:          testUsername,
+		Password:          testPassword,
+		PlainPassword:     true,
+		CachePasswordHash: true,
+	})
+}
+
+func TestClient_CachePasswordHash(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestCachingClient(t, server)
+
+	for i := 0; i < 3; i++ {
+		// the token expired, the hash is still cached
+		if err := client.AuthStore().Delete(testUsername); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.GetPortfolioSummary(); err != nil {
+			t.Fatalf("GetPortfolioSummary() error = %v", err)
+		}
+	}
+
+	logins, activations := server.LoginCount(), server.RequestCount("/activation/generated")
+	if logins != 3 || activations != 1 {
+		t.Errorf("logins = %v, activations = %v, want %v, %v", logins, activations, 3, 1)
+	}
+
+	// a changed password must not reuse the hash of the previous one
+	server.AddAccount(kseitest.SampleAccount(testUsername, "new-plain-password"))
+	client.SetAuth(testUsername, "new-plain-password")
+
+	if got, _ := client.CachedPasswordHash(testPassword); got != "" {
+		t.Errorf("CachedPasswordHash() after SetAuth = %v, want none", got)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	if got := server.RequestCount("/activation/generated"); got != 2 {
+		t.Errorf("activations = %v, want %v", got, 2)
+	}
+
+	// signing out removes the hash along with the token
+	if err := client.ClearToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := client.CachedPasswordHash("new-plain-password"); got != "" {
+		t.Errorf("CachedPasswordHash() after ClearToken = %v, want none", got)
+	}
+}
+
+func TestClient_CachePasswordHash_unencryptedStore(t *testing.T) {
+	server := newTestServer(t)
+
+	authStore := goksei.NewMemoryAuthStore()
+	client := server.NewClient(goksei.ClientOpts{
+		AuthStore:         authStore,
+		Username:          testUsername,
+		Password:          testPassword,
+		PlainPassword:     true,
+		CachePasswordHash: true,
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	var cached map[string]any
+	if found, _ := authStore.Get(testUsername+goksei.PasswordHashKeySuffix, &cached); found {
+		t.Errorf("password hash cached in an unencrypted store")
+	}
+}
+
+func TestClient_CachePasswordHash_rejected(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestCachingClient(t, server)
+
+	if err := client.StorePasswordHash(testPassword, "stale-hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	want := kseitest.HashPassword(testPassword)
+	if got, _ := client.CachedPasswordHash(testPassword); got != want {
+		t.Errorf("CachedPasswordHash() = %v, want %v", got, want)
+	}
+
+	attempts, activations := server.RequestCount("/login"), server.RequestCount("/activation/generated")
+	if attempts != 2 || activations != 1 {
+		t.Errorf("login attempts = %v, activations = %v, want %v, %v", attempts, activations, 2, 1)
+	}
+}
+
+func TestClient_CachePasswordHash_wrongPassword(t *testing.T) {
+	server := newTestServer(t)
+	server.AddAccount(kseitest.SampleAccount(testUsername, "changed-password"))
+	client := newTestCachingClient(t, server)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := client.GetPortfolioSummary(); !errors.Is(err, goksei.ErrInvalidCredentials) {
+			t.Fatalf("GetPortfolioSummary() error = %v, want %v", err, goksei.ErrInvalidCredentials)
+		}
+
+		// a rejected hash is never cached, so every call asks for a fresh one and logs in once
+		attempts, activations := server.RequestCount("/login"), server.RequestCount("/activation/generated")
+		if attempts != i || activations != i {
+			t.Errorf("call %d: login attempts = %v, activations = %v, want %v each", i, attempts, activations, i)
+		}
+	}
+
+	if got, _ := client.CachedPasswordHash(testPassword); got != "" {
+		t.Errorf("CachedPasswordHash() = %v, want none", got)
+	}
+}
+
+func TestClient_HashPassword(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestCachingClient(t, server)
+
+	got, err := client.HashPassword(t.Context(), testPassword)
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if want := kseitest.HashPassword(testPassword); got != want {
+		t.Errorf("HashPassword() = %v, want %v", got, want)
+	}
+}
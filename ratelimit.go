@@ -0,0 +1,72 @@
+package goksei
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter throttles outgoing requests to KSEI.
+// Wait blocks until a request identified by key may be sent or ctx is done.
+// A single RateLimiter can be shared by multiple clients to enforce a combined limit.
+type RateLimiter interface {
+	Wait(ctx context.Context, key RateLimitKey) error
+}
+
+// RateLimitKey identifies the origin of a request passed to a RateLimiter.
+type RateLimitKey struct {
+	BaseURL  string
+	Username string
+}
+
+// TokenBucketOpts contains configuration options for NewTokenBucketLimiter.
+type TokenBucketOpts struct {
+	Rate        float64 // sustained requests per second, zero or less means no limit
+	Burst       int     // maximum requests sent at once (default: 1)
+	PerUsername bool    // keep a separate bucket per username instead of sharing one per base URL
+}
+
+type tokenBucketLimiter struct {
+	opts TokenBucketOpts
+
+	mu      sync.Mutex
+	buckets map[RateLimitKey]*rate.Limiter
+}
+
+// NewTokenBucketLimiter creates a RateLimiter with one token bucket per base URL,
+// or per base URL and username when PerUsername is set. Without a positive Rate,
+// requests are never throttled.
+func NewTokenBucketLimiter(opts TokenBucketOpts) RateLimiter {
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+
+	return &tokenBucketLimiter{
+		opts:    opts,
+		buckets: make(map[RateLimitKey]*rate.Limiter),
+	}
+}
+
+func (l *tokenBucketLimiter) Wait(ctx context.Context, key RateLimitKey) error {
+	if !l.opts.PerUsername {
+		key.Username = ""
+	}
+
+	l.mu.Lock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		limit := rate.Inf
+		if l.opts.Rate > 0 {
+			limit = rate.Limit(l.opts.Rate)
+		}
+
+		bucket = rate.NewLimiter(limit, l.opts.Burst)
+		l.buckets[key] = bucket
+	}
+
+	l.mu.Unlock()
+
+	return bucket.Wait(ctx)
+}
@@ -0,0 +1,129 @@
+package goksei_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	alice := goksei.RateLimitKey{BaseURL: "https://akses.ksei.co.id/service", Username: "alice"}
+	bob := goksei.RateLimitKey{BaseURL: "https://akses.ksei.co.id/service", Username: "bob"}
+
+	tests := []struct {
+		name        string
+		perUsername bool
+		wantBobWait bool
+	}{
+		{name: "shared_bucket", perUsername: false, wantBobWait: true},
+		{name: "per_username", perUsername: true, wantBobWait: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := goksei.NewTokenBucketLimiter(goksei.TokenBucketOpts{Rate: 0.01, PerUsername: tt.perUsername})
+
+			if err := limiter.Wait(context.Background(), alice); err != nil {
+				t.Fatalf("first Wait() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			err := limiter.Wait(ctx, bob)
+			if gotWait := err != nil; gotWait != tt.wantBobWait {
+				t.Errorf("Wait() error = %v, want throttled %v", err, tt.wantBobWait)
+			}
+		})
+	}
+}
+
+func TestTokenBucketLimiter_noRate(t *testing.T) {
+	limiter := goksei.NewTokenBucketLimiter(goksei.TokenBucketOpts{})
+	key := goksei.RateLimitKey{BaseURL: "https://akses.ksei.co.id/service", Username: "alice"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		if err := limiter.Wait(ctx, key); err != nil {
+			t.Fatalf("Wait(#%d) error = %v, want no limit", i, err)
+		}
+	}
+}
+
+// recordingLimiter records the keys waited on before passing them to limiter.
+type recordingLimiter struct {
+	limiter goksei.RateLimiter
+
+	mu   sync.Mutex
+	keys []goksei.RateLimitKey
+}
+
+func (r *recordingLimiter) Wait(ctx context.Context, key goksei.RateLimitKey) error {
+	r.mu.Lock()
+	r.keys = append(r.keys, key)
+	r.mu.Unlock()
+
+	return r.limiter.Wait(ctx, key)
+}
+
+func TestClient_RateLimiter_shared(t *testing.T) {
+	server := newTestServer(t)
+
+	// a login and a data request per client, at most one request every 50ms across both
+	limiter := &recordingLimiter{limiter: goksei.NewTokenBucketLimiter(goksei.TokenBucketOpts{Rate: 20})}
+
+	var clients []*goksei.Client
+	for _, username := range []string{"alice@example.com", "bob@example.com"} {
+		server.AddAccount(kseitest.SampleAccount(username, testPassword))
+
+		client := server.NewClient(goksei.ClientOpts{
+			Username:    username,
+			Password:    kseitest.HashPassword(testPassword),
+			RateLimiter: limiter,
+		})
+		clients = append(clients, client)
+	}
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.GetPortfolioSummary(); err != nil {
+				t.Errorf("GetPortfolioSummary() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// four requests through one bucket: the first is free, the others wait for a token each
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("requests took %v, want them throttled together", elapsed)
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	perUser := map[string]int{}
+	for _, key := range limiter.keys {
+		if key.BaseURL != server.URL {
+			t.Errorf("Wait() key base URL = %v, want %v", key.BaseURL, server.URL)
+		}
+
+		perUser[key.Username]++
+	}
+
+	if perUser["alice@example.com"] != 2 || perUser["bob@example.com"] != 2 {
+		t.Errorf("Wait() calls per user = %v, want 2 each", perUser)
+	}
+}
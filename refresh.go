@@ -0,0 +1,78 @@
+package goksei
+
+import (
+	"context"
+	"time"
+)
+
+const defaultRefreshInterval = time.Minute
+
+// refresher holds the state of the background token refresher.
+type refresher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartRefresher starts a background goroutine that checks the cached token every interval
+// and logs in again once less than the configured RefreshSkew remains, so long-running
+// services always hold a fresh token. An interval of 0 defaults to one minute.
+// Refresh failures are retried on the next tick. Calling StartRefresher while a refresher
+// is already running restarts it with the new interval. ClearToken and Logout stop it.
+// The refresher requires an AuthStore, as tokens are not cached without one.
+func (c *Client) StartRefresher(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+
+	// stopping and installing under one lock, so concurrent calls cannot leak a refresher
+	c.refresherMu.Lock()
+	defer c.refresherMu.Unlock()
+
+	c.stopRefresherLocked()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &refresher{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	c.refresher = r
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if c.authStore != nil {
+				_, _ = c.getToken(ctx, c.config())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopRefresher stops the background refresher started by StartRefresher
+// and waits for it to exit. It is a no-op if no refresher is running.
+func (c *Client) StopRefresher() {
+	c.refresherMu.Lock()
+	defer c.refresherMu.Unlock()
+
+	c.stopRefresherLocked()
+}
+
+// stopRefresherLocked stops the running refresher, if any. c.refresherMu must be held.
+func (c *Client) stopRefresherLocked() {
+	if c.refresher == nil {
+		return
+	}
+
+	c.refresher.cancel()
+	<-c.refresher.done
+	c.refresher = nil
+}
@@ -0,0 +1,148 @@
+package goksei_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestClient_Get_refreshSkew(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	expiring := newTestToken(t, time.Now().Add(30*time.Second), 0)
+	if err := client.AuthStore().Set(testUsername, expiring); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	// a token within the refresh skew is replaced before it is sent
+	if got := server.RequestCount("/myportofolio/summary"); got != 1 {
+		t.Errorf("summary requests = %v, want %v", got, 1)
+	}
+
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+func TestClient_StartRefresher(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	expiring := newTestToken(t, time.Now().Add(30*time.Second), 0)
+	if err := client.AuthStore().Set(testUsername, expiring); err != nil {
+		t.Fatal(err)
+	}
+
+	client.StartRefresher(10 * time.Millisecond)
+	defer client.StopRefresher()
+
+	deadline := time.Now().Add(time.Second)
+	for server.LoginCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	client.StopRefresher()
+
+	// the fresh token is outside the skew, so no further logins should happen
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+// countingAuthStore counts the reads of the AuthStore it wraps.
+type countingAuthStore struct {
+	goksei.AuthStore
+
+	gets atomic.Int64
+}
+
+func (s *countingAuthStore) Get(k string, v any) (bool, error) {
+	s.gets.Add(1)
+
+	return s.AuthStore.Get(k, v)
+}
+
+func TestClient_StartRefresher_concurrent(t *testing.T) {
+	server := newTestServer(t)
+
+	store := &countingAuthStore{AuthStore: goksei.NewMemoryAuthStore()}
+	client := server.NewClient(goksei.ClientOpts{
+		AuthStore: store,
+		Username:  testUsername,
+		Password:  kseitest.HashPassword(testPassword),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			client.StartRefresher(5 * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+
+	client.StopRefresher()
+
+	// a leaked refresher would keep reading the cached token on every tick,
+	// once a login it may have left behind has finished
+	time.Sleep(20 * time.Millisecond)
+	before := store.gets.Load()
+	time.Sleep(50 * time.Millisecond)
+
+	if after := store.gets.Load(); after != before {
+		t.Errorf("AuthStore read %d times after StopRefresher, want no refresher left", after-before)
+	}
+}
+
+func TestClient_StartRefresher_stoppedBySignOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		signOut func(*goksei.Client) error
+	}{
+		{name: "logout", signOut: (*goksei.Client).Logout},
+		{name: "clear_token", signOut: (*goksei.Client).ClearToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			client := newTestClient(t, server)
+
+			client.StartRefresher(20 * time.Millisecond)
+			defer client.StopRefresher()
+
+			deadline := time.Now().Add(time.Second)
+			for server.LoginCount() == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if err := tt.signOut(client); err != nil {
+				t.Fatalf("sign out error = %v", err)
+			}
+
+			// several ticks later the refresher must not have logged in again
+			time.Sleep(100 * time.Millisecond)
+
+			if _, err := client.Session(); !errors.Is(err, goksei.ErrNoSession) {
+				t.Errorf("Session() error = %v, want %v", err, goksei.ErrNoSession)
+			}
+
+			if got := server.LoginCount(); got != 1 {
+				t.Errorf("login count = %v, want %v", got, 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package goksei
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how failed requests to KSEI are retried.
+// It applies to every request made by the client: password hashing, login and data requests.
+// A nil policy or a MaxAttempts of 1 or less disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, doubled on each subsequent retry (default: 500ms).
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between attempts, including delays requested by Retry-After (default: 30s).
+	MaxDelay time.Duration
+
+	// Jitter randomly shortens each delay by up to this fraction (0 to 1) to spread out retries.
+	Jitter float64
+
+	// RetryableStatusCodes lists the HTTP statuses worth retrying (default: 429, 502, 503 and 504).
+	// Network errors and timeouts are always retried.
+	RetryableStatusCodes []int
+
+	// OnRetry, if set, is called before sleeping ahead of each retry.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	Request *http.Request
+	Attempt int           // number of the failed attempt, starting at 1
+	Delay   time.Duration // delay before the next attempt
+	Err     error         // error of the failed attempt
+}
+
+// DefaultRetryPolicy returns a policy suitable for KSEI's occasional maintenance hiccups:
+// up to 4 attempts with exponential backoff from 500ms to 30s and 20% jitter.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (p *RetryPolicy) shouldRetry(req *http.Request, attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	// the caller gave up, no point in trying again
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	// the body cannot be replayed
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	// neither goes away within a few retries
+	if errors.Is(err, ErrMaintenance) || errors.Is(err, ErrResponseTooLarge) {
+		return false
+	}
+
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		codes := p.RetryableStatusCodes
+		if codes == nil {
+			codes = defaultRetryableStatusCodes
+		}
+
+		return slices.Contains(codes, respErr.StatusCode)
+	}
+
+	return true
+}
+
+func (p *RetryPolicy) delay(attempt int, header http.Header) time.Duration {
+	baseDelay := p.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = 500 * time.Millisecond
+	}
+
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	if d, ok := retryAfter(header, time.Now()); ok {
+		return min(d, maxDelay)
+	}
+
+	d := baseDelay << (attempt - 1)
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(d))
+	}
+
+	return d
+}
+
+// retryAfter parses the Retry-After header, either in seconds or as an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// rewind prepares req to be sent again, replaying its body if it has one.
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = body
+
+	return req, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
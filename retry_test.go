@@ -0,0 +1,103 @@
+package goksei_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestClient_retryPolicy(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail(kseitest.Failure{Path: "/login", StatusCode: http.StatusBadGateway, Times: 1})
+	server.Fail(kseitest.Failure{
+		Path:       "/myportofolio/summary",
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": {"0"}},
+		Times:      1,
+	})
+
+	var events []goksei.RetryEvent
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username: testUsername,
+		Password: kseitest.HashPassword(testPassword),
+		RetryPolicy: &goksei.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			OnRetry: func(e goksei.RetryEvent) {
+				events = append(events, e)
+			},
+		},
+	})
+
+	if _, err := client.GetPortfolioSummary(); err != nil {
+		t.Fatalf("GetPortfolioSummary() error = %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("OnRetry called %d times, want %d", len(events), 2)
+	}
+
+	if !errors.Is(events[0].Err, goksei.ErrServerUnavailable) || events[0].Attempt != 1 {
+		t.Errorf("OnRetry event = %+v, want first attempt failing with %v", events[0], goksei.ErrServerUnavailable)
+	}
+
+	// the login was retried with the same payload
+	if got := server.LoginCount(); got != 1 {
+		t.Errorf("login count = %v, want %v", got, 1)
+	}
+}
+
+func TestClient_retryPolicy_nonRetryableStatus(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail(kseitest.Failure{StatusCode: http.StatusNotFound})
+
+	client := server.NewClient(goksei.ClientOpts{
+		Username:    testUsername,
+		Password:    kseitest.HashPassword(testPassword),
+		RetryPolicy: &goksei.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	})
+
+	if _, err := client.GetPortfolioSummary(); !errors.Is(err, goksei.ErrUnexpectedResponse) {
+		t.Fatalf("GetPortfolioSummary() error = %v, want %v", err, goksei.ErrUnexpectedResponse)
+	}
+
+	if got := server.RequestCount("/login"); got != 1 {
+		t.Errorf("attempts = %v, want %v", got, 1)
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "empty", value: "", want: 0, wantOk: false},
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOk: true},
+		{name: "date", value: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, wantOk: true},
+		{name: "past_date", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOk: true},
+		{name: "invalid", value: "soon", want: 0, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+
+			got, gotOk := goksei.RetryAfter(header, now)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
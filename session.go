@@ -0,0 +1,82 @@
+package goksei
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNoSession is returned by Client.Session when there is no cached session for the user.
+var ErrNoSession = errors.New("no session")
+
+// Session describes an authenticated KSEI session. It is what the client stores in the AuthStore.
+type Session struct {
+	Username  string         `json:"username"`
+	Token     string         `json:"token"`             // raw JWT bearer token
+	IssuedAt  time.Time      `json:"issuedAt,omitzero"` // zero if the token has no iat claim
+	ExpiresAt time.Time      `json:"expiresAt"`         // from the exp claim
+	Claims    map[string]any `json:"claims,omitempty"`  // all claims of the token, unverified
+}
+
+// newSession creates a session from a raw JWT, reading its claims without verifying the signature.
+func newSession(username, rawToken string) (*Session, error) {
+	claims, err := parseClaims(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	expire, err := claimTime(claims, "exp")
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		Username:  username,
+		Token:     rawToken,
+		ExpiresAt: *expire,
+		Claims:    claims,
+	}
+
+	if issued, err := claimTime(claims, "iat"); err == nil {
+		session.IssuedAt = *issued
+	}
+
+	return session, nil
+}
+
+// Remaining returns how long the session stays valid, or zero if it has expired.
+func (s *Session) Remaining() time.Duration {
+	return max(time.Until(s.ExpiresAt), 0)
+}
+
+// Expired reports whether the session has expired.
+func (s *Session) Expired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// UnmarshalJSON decodes a session, also accepting the bare token string
+// stored by previous versions of this library.
+func (s *Session) UnmarshalJSON(data []byte) error {
+	var rawToken string
+	if err := json.Unmarshal(data, &rawToken); err == nil {
+		if rawToken == "" {
+			*s = Session{}
+
+			return nil
+		}
+
+		session, err := newSession("", rawToken)
+		if err != nil {
+			return err
+		}
+
+		*s = *session
+
+		return nil
+	}
+
+	// avoid recursing into this method
+	type plainSession Session
+
+	return json.Unmarshal(data, (*plainSession)(s))
+}
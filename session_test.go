@@ -0,0 +1,51 @@
+package goksei_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+)
+
+func TestSession_UnmarshalJSON(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := newTestToken(t, exp, 1)
+
+	session, err := goksei.NewSession("alice", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		data         []byte
+		wantUsername string
+	}{
+		{name: "session", data: current, wantUsername: "alice"},
+		{name: "legacy_bare_token", data: legacy, wantUsername: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got goksei.Session
+			if err := json.Unmarshal(tt.data, &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			if got.Token != token || !got.ExpiresAt.Equal(exp) || got.Username != tt.wantUsername {
+				t.Errorf("json.Unmarshal() = %+v, want token expiring at %v", got, exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package goksei
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrNotReconciled is returned when the holdings of a Snapshot do not add up
+// to the total reported by the portfolio summary.
+var ErrNotReconciled = errors.New("holdings do not reconcile with portfolio summary")
+
+// ShareTypes lists the portfolio types holding shares or units, i.e. all but CashType.
+var ShareTypes = []PortfolioType{EquityType, MutualFundType, BondType, OtherType}
+
+// reconcileTolerance is the difference allowed per holding when reconciling a Snapshot,
+// as KSEI rounds each line of the summary to the rupiah.
+const reconcileTolerance = 1.0
+
+// SnapshotStore keeps the history of portfolio snapshots, e.g. to track net worth over time.
+// See NewSQLSnapshotStore.
+type SnapshotStore interface {
+	// Save stores a complete snapshot. It reports false without storing anything when the
+	// snapshot is identical to the previous one of the same account.
+	Save(ctx context.Context, snapshot *Snapshot) (bool, error)
+
+	// Query returns the snapshots matching query, oldest first.
+	Query(ctx context.Context, query SnapshotQuery) ([]*Snapshot, error)
+
+	// Latest returns the most recent snapshot of username, or ErrNoSnapshot.
+	Latest(ctx context.Context, username string) (*Snapshot, error)
+
+	Close() error
+}
+
+// SnapshotQuery selects snapshots from a SnapshotStore. Zero fields match everything.
+type SnapshotQuery struct {
+	Username string
+	From     time.Time // inclusive
+	To       time.Time // exclusive
+}
+
+// ErrNoSnapshot is returned by SnapshotStore.Latest when no snapshot was saved for the account.
+var ErrNoSnapshot = errors.New("no snapshot")
+
+// Snapshot is the whole portfolio of an account at one point in time, as returned by GetSnapshot.
+type Snapshot struct {
+	Username string
+	Time     time.Time // when the snapshot was requested
+
+	Summary  *PortfolioSummaryResponse        // nil if the summary could not be fetched
+	Cash     []CashBalance                    // nil if cash balances could not be fetched
+	Holdings map[PortfolioType][]ShareBalance // keyed by the types in ShareTypes that were fetched
+
+	// Totals are in IDR. Holdings in other currencies cannot be converted, as KSEI does not
+	// report their exchange rate: they are left out of HoldingTotals and Total and summed
+	// separately in ForeignTotals.
+	CashTotal     float64                   // sum of CashBalance.CurrentBalance, i.e. the IDR value
+	HoldingTotals map[PortfolioType]float64 // sum of ShareBalance.CurrentValue of IDR holdings per type
+	ForeignTotals map[string]float64        // sum of ShareBalance.CurrentValue of other holdings per currency
+	Total         float64                   // CashTotal plus all HoldingTotals
+
+	SummaryErr error                   // error fetching the summary, if any
+	Errors     map[PortfolioType]error // errors fetching cash (CashType) or holdings, by type
+}
+
+// Complete reports whether every part of the snapshot was fetched.
+func (s *Snapshot) Complete() bool {
+	return s.SummaryErr == nil && len(s.Errors) == 0
+}
+
+// Reconcile checks that Total matches the total of the portfolio summary.
+// It returns nil if the snapshot is incomplete, as there is nothing to compare then.
+// The summary includes the IDR value of foreign currency holdings, which is unknown:
+// with such holdings, Reconcile only checks that Total does not exceed the summary total.
+func (s *Snapshot) Reconcile() error {
+	if !s.Complete() || s.Summary == nil {
+		return nil
+	}
+
+	lines := len(s.Cash)
+	for _, holdings := range s.Holdings {
+		lines += len(holdings)
+	}
+
+	diff := s.Total - s.Summary.Total
+	if len(s.ForeignTotals) > 0 {
+		diff = max(diff, 0)
+	}
+
+	if math.Abs(diff) <= reconcileTolerance*float64(max(lines, 1)) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: holdings total %.2f, summary total %.2f", ErrNotReconciled, s.Total, s.Summary.Total)
+}
+
+// Err returns the errors of the parts that could not be fetched, joined with the
+// reconciliation error if any.
+func (s *Snapshot) Err() error {
+	errs := []error{s.SummaryErr}
+
+	if err := s.Errors[CashType]; err != nil {
+		errs = append(errs, fmt.Errorf("cash: %w", err))
+	}
+
+	for _, portfolioType := range ShareTypes {
+		if err := s.Errors[portfolioType]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", portfolioType.Name(), err))
+		}
+	}
+
+	return errors.Join(append(errs, s.Reconcile())...)
+}
+
+// GetSnapshot fetches the portfolio summary, cash balances and the holdings of every type
+// in ShareTypes concurrently and merges them into a Snapshot.
+//
+// A failing request does not affect the others: the snapshot is returned even when err is
+// non-nil, holding whatever could be fetched. The error is the one returned by Snapshot.Err.
+func (c *Client) GetSnapshot(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Username: c.config().username,
+		Time:     time.Now(),
+		Holdings: make(map[PortfolioType][]ShareBalance),
+		Errors:   make(map[PortfolioType]error),
+	}
+
+	var (
+		mu    sync.Mutex
+		group errgroup.Group
+	)
+
+	group.Go(func() error {
+		summary, err := c.GetPortfolioSummaryContext(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		snapshot.Summary, snapshot.SummaryErr = summary, err
+
+		return nil
+	})
+
+	group.Go(func() error {
+		cash, err := c.GetCashBalancesContext(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			snapshot.Errors[CashType] = err
+
+			return nil
+		}
+
+		snapshot.Cash = cash.Data
+
+		return nil
+	})
+
+	for _, portfolioType := range ShareTypes {
+		group.Go(func() error {
+			shares, err := c.GetShareBalancesContext(ctx, portfolioType)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				snapshot.Errors[portfolioType] = err
+
+				return nil
+			}
+
+			snapshot.Holdings[portfolioType] = shares.Data
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	snapshot.computeTotals()
+
+	return snapshot, snapshot.Err()
+}
+
+// computeTotals sets CashTotal, HoldingTotals, ForeignTotals and Total from Cash and Holdings.
+func (s *Snapshot) computeTotals() {
+	s.CashTotal = 0
+	for _, balance := range s.Cash {
+		s.CashTotal += balance.CurrentBalance()
+	}
+
+	s.HoldingTotals = make(map[PortfolioType]float64, len(s.Holdings))
+	s.ForeignTotals = nil
+	s.Total = s.CashTotal
+
+	for portfolioType, holdings := range s.Holdings {
+		var total float64
+		for _, balance := range holdings {
+			if balance.Currency != "" && balance.Currency != "IDR" {
+				if s.ForeignTotals == nil {
+					s.ForeignTotals = make(map[string]float64)
+				}
+
+				s.ForeignTotals[balance.Currency] += balance.CurrentValue()
+
+				continue
+			}
+
+			total += balance.CurrentValue()
+		}
+
+		s.HoldingTotals[portfolioType] = total
+		s.Total += total
+	}
+}
@@ -0,0 +1,219 @@
+package goksei
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultSQLSnapshotTable = "goksei_snapshots"
+
+// SQLSnapshotStoreOpts contains configuration options for NewSQLSnapshotStore.
+type SQLSnapshotStoreOpts struct {
+	TableName          string // default: "goksei_snapshots"
+	DollarPlaceholders bool   // use $1, $2, ... placeholders (PostgreSQL) instead of ?
+}
+
+// SQLSnapshotStore is a SnapshotStore backed by database/sql, e.g. a local SQLite file.
+// Call Migrate once before use to create or upgrade the table.
+type SQLSnapshotStore struct {
+	db    *sql.DB
+	table string
+	opts  SQLSnapshotStoreOpts
+}
+
+// snapshotData is the part of a Snapshot persisted by SQLSnapshotStore.
+// Totals are derived from it when loading.
+type snapshotData struct {
+	Summary  *PortfolioSummaryResponse        `json:"summary"`
+	Cash     []CashBalance                    `json:"cash"`
+	Holdings map[PortfolioType][]ShareBalance `json:"holdings"`
+}
+
+// NewSQLSnapshotStore creates a snapshot store using db.
+// The store does not take ownership of db: Close leaves it open.
+func NewSQLSnapshotStore(db *sql.DB, opts SQLSnapshotStoreOpts) (*SQLSnapshotStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+
+	table := opts.TableName
+	if table == "" {
+		table = defaultSQLSnapshotTable
+	}
+
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	return &SQLSnapshotStore{
+		db:    db,
+		table: table,
+		opts:  opts,
+	}, nil
+}
+
+// Migrate creates the snapshot table or upgrades it to the latest schema.
+// Applied migrations are recorded in a "<table>_migrations" table, so it is safe to call
+// Migrate on every start, including from several replicas starting at the same time.
+func (s *SQLSnapshotStore) Migrate(ctx context.Context) error {
+	return migrateSQL(ctx, s.db, s.table+"_migrations", s.opts.DollarPlaceholders, []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+			username VARCHAR(255) NOT NULL,
+			taken_at BIGINT NOT NULL,
+			fingerprint VARCHAR(64) NOT NULL,
+			total DOUBLE PRECISION NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (username, taken_at)
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_taken_at ON ` + s.table + ` (taken_at)`,
+	})
+}
+
+// Save stores snapshot unless it is identical to the previous snapshot of the same account.
+// Incomplete snapshots are rejected, as they would show up as drops in the history.
+func (s *SQLSnapshotStore) Save(ctx context.Context, snapshot *Snapshot) (bool, error) {
+	if !snapshot.Complete() {
+		return false, fmt.Errorf("cannot save incomplete snapshot: %w", snapshot.Err())
+	}
+
+	data, err := json.Marshal(snapshotData{
+		Summary:  snapshot.Summary,
+		Cash:     snapshot.Cash,
+		Holdings: snapshot.Holdings,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	sum := sha256.Sum256(data)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var previous string
+
+	err = tx.QueryRowContext(ctx, s.query(`SELECT fingerprint FROM `+s.table+
+		` WHERE username = ? AND taken_at <= ? ORDER BY taken_at DESC LIMIT 1`),
+		snapshot.Username, snapshot.Time.UnixMilli()).Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	if previous == fingerprint {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO `+s.table+
+		` (username, taken_at, fingerprint, total, data) VALUES (?, ?, ?, ?, ?)`),
+		snapshot.Username, snapshot.Time.UnixMilli(), fingerprint, snapshot.Total, string(data)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Query returns the snapshots matching query, oldest first.
+func (s *SQLSnapshotStore) Query(ctx context.Context, query SnapshotQuery) ([]*Snapshot, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if query.Username != "" {
+		conditions = append(conditions, "username = ?")
+		args = append(args, query.Username)
+	}
+
+	if !query.From.IsZero() {
+		conditions = append(conditions, "taken_at >= ?")
+		args = append(args, query.From.UnixMilli())
+	}
+
+	if !query.To.IsZero() {
+		conditions = append(conditions, "taken_at < ?")
+		args = append(args, query.To.UnixMilli())
+	}
+
+	q := `SELECT username, taken_at, data FROM ` + s.table
+	if len(conditions) > 0 {
+		q += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	return s.scan(ctx, q+` ORDER BY taken_at, username`, args...)
+}
+
+// Latest returns the most recent snapshot of username, or ErrNoSnapshot.
+func (s *SQLSnapshotStore) Latest(ctx context.Context, username string) (*Snapshot, error) {
+	snapshots, err := s.scan(ctx, `SELECT username, taken_at, data FROM `+s.table+
+		` WHERE username = ? ORDER BY taken_at DESC LIMIT 1`, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoSnapshot, username)
+	}
+
+	return snapshots[0], nil
+}
+
+// Close is a no-op, the database is owned by the caller.
+func (s *SQLSnapshotStore) Close() error {
+	return nil
+}
+
+func (s *SQLSnapshotStore) scan(ctx context.Context, q string, args ...any) ([]*Snapshot, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(q), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []*Snapshot
+
+	for rows.Next() {
+		var (
+			username string
+			takenAt  int64
+			raw      string
+			data     snapshotData
+		)
+
+		if err := rows.Scan(&username, &takenAt, &raw); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, fmt.Errorf("error decoding snapshot of %s at %d: %w", username, takenAt, err)
+		}
+
+		snapshot := &Snapshot{
+			Username: username,
+			Time:     time.UnixMilli(takenAt),
+			Summary:  data.Summary,
+			Cash:     data.Cash,
+			Holdings: data.Holdings,
+			Errors:   map[PortfolioType]error{},
+		}
+		snapshot.computeTotals()
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+func (s *SQLSnapshotStore) query(q string) string {
+	return rebindSQL(q, s.opts.DollarPlaceholders)
+}
@@ -0,0 +1,123 @@
+package goksei_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chickenzord/goksei"
+)
+
+func newTestSQLSnapshotStore(t *testing.T) *goksei.SQLSnapshotStore {
+	t.Helper()
+
+	store, err := goksei.NewSQLSnapshotStore(newTestSQLDB(t), goksei.SQLSnapshotStoreOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// migrating twice must be a no-op
+	for i := 0; i < 2; i++ {
+		if err := store.Migrate(t.Context()); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+
+	return store
+}
+
+func newTestSnapshot(username string, at time.Time, cash float64) *goksei.Snapshot {
+	snapshot := &goksei.Snapshot{
+		Username: username,
+		Time:     at,
+		Summary:  &goksei.PortfolioSummaryResponse{Total: cash + 90_000},
+		Cash:     []goksei.CashBalance{{AccountNumber: "123", Currency: "IDR", Balance: cash, BalanceIDR: cash}},
+		Holdings: map[goksei.PortfolioType][]goksei.ShareBalance{
+			goksei.EquityType: {{Account: "XL001", FullName: "BBCA - BANK CENTRAL ASIA Tbk", Amount: 10, ClosingPrice: 9_000}},
+		},
+	}
+	snapshot.ComputeTotals()
+
+	return snapshot
+}
+
+func TestSQLSnapshotStore(t *testing.T) {
+	store := newTestSQLSnapshotStore(t)
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	saves := []struct {
+		snapshot *goksei.Snapshot
+		want     bool
+	}{
+		{newTestSnapshot("alice", start, 1_000), true},
+		{newTestSnapshot("alice", start.Add(time.Hour), 1_000), false}, // unchanged
+		{newTestSnapshot("alice", start.Add(24*time.Hour), 2_000), true},
+		{newTestSnapshot("bob", start.Add(time.Hour), 1_000), true}, // same content, other account
+		{newTestSnapshot("alice", start.Add(48*time.Hour), 1_000), true},
+	}
+
+	for i, save := range saves {
+		saved, err := store.Save(t.Context(), save.snapshot)
+		if err != nil {
+			t.Fatalf("Save(#%d) error = %v", i, err)
+		}
+
+		if saved != save.want {
+			t.Errorf("Save(#%d) = %v, want %v", i, saved, save.want)
+		}
+	}
+
+	all, err := store.Query(t.Context(), goksei.SnapshotQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 4 {
+		t.Fatalf("Query() returned %d snapshots, want 4", len(all))
+	}
+
+	alice, err := store.Query(t.Context(), goksei.SnapshotQuery{
+		Username: "alice",
+		From:     start.Add(time.Hour),
+		To:       start.Add(48 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(alice) != 1 || !alice[0].Time.Equal(start.Add(24*time.Hour)) {
+		t.Fatalf("Query(alice) = %v, want the snapshot of the second day", alice)
+	}
+
+	if got := alice[0]; got.Total != 92_000 || got.CashTotal != 2_000 || got.HoldingTotals[goksei.EquityType] != 90_000 {
+		t.Errorf("loaded totals = %v, %v, %v", got.Total, got.CashTotal, got.HoldingTotals)
+	}
+
+	if err := alice[0].Reconcile(); err != nil {
+		t.Errorf("loaded snapshot Reconcile() = %v", err)
+	}
+
+	latest, err := store.Latest(t.Context(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !latest.Time.Equal(start.Add(48 * time.Hour)) {
+		t.Errorf("Latest() time = %v", latest.Time)
+	}
+
+	if _, err := store.Latest(t.Context(), "carol"); !errors.Is(err, goksei.ErrNoSnapshot) {
+		t.Errorf("Latest(carol) error = %v, want %v", err, goksei.ErrNoSnapshot)
+	}
+}
+
+func TestSQLSnapshotStore_rejectsIncomplete(t *testing.T) {
+	store := newTestSQLSnapshotStore(t)
+
+	snapshot := newTestSnapshot("alice", time.Now(), 1_000)
+	snapshot.Errors = map[goksei.PortfolioType]error{goksei.BondType: goksei.ErrServerUnavailable}
+
+	if _, err := store.Save(t.Context(), snapshot); !errors.Is(err, goksei.ErrServerUnavailable) {
+		t.Errorf("Save() error = %v, want %v", err, goksei.ErrServerUnavailable)
+	}
+}
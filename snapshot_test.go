@@ -0,0 +1,133 @@
+package goksei_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/chickenzord/goksei"
+	"github.com/chickenzord/goksei/kseitest"
+)
+
+func TestClient_GetSnapshot(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+
+	snapshot, err := client.GetSnapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !snapshot.Complete() {
+		t.Errorf("snapshot is incomplete: %v", snapshot.Errors)
+	}
+
+	if snapshot.Username != testUsername || snapshot.Time.IsZero() {
+		t.Errorf("snapshot username = %q, time = %v", snapshot.Username, snapshot.Time)
+	}
+
+	if snapshot.CashTotal != 1_500_000 {
+		t.Errorf("cash total = %v, want 1500000", snapshot.CashTotal)
+	}
+
+	if got := snapshot.HoldingTotals[goksei.EquityType]; got != 5_200_000 {
+		t.Errorf("equity total = %v, want 5200000", got)
+	}
+
+	if got := snapshot.HoldingTotals[goksei.MutualFundType]; got != 1_250_625 {
+		t.Errorf("mutual fund total = %v, want 1250625", got)
+	}
+
+	// the sample account has no bonds and other holdings
+	if got := len(snapshot.Holdings); got != len(goksei.ShareTypes) {
+		t.Errorf("holdings fetched for %d types, want %d", got, len(goksei.ShareTypes))
+	}
+
+	if snapshot.Total != sampleTotal {
+		t.Errorf("total = %v, want %v", snapshot.Total, sampleTotal)
+	}
+}
+
+func TestClient_GetSnapshot_notReconciled(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail(kseitest.Failure{
+		Path:       "/myportofolio/summary",
+		StatusCode: http.StatusOK,
+		Body:       `{"summaryValue":100000,"summaryResponse":[]}`,
+	})
+
+	client := newTestClient(t, server)
+
+	snapshot, err := client.GetSnapshot(context.Background())
+	if !errors.Is(err, goksei.ErrNotReconciled) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrNotReconciled)
+	}
+
+	if !snapshot.Complete() {
+		t.Errorf("snapshot is incomplete: %v", snapshot.Errors)
+	}
+}
+
+func TestClient_GetSnapshot_partialFailure(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail(kseitest.Failure{Path: "/myportofolio/summary-detail/obligasi", StatusCode: http.StatusBadGateway})
+
+	client := newTestClient(t, server)
+
+	snapshot, err := client.GetSnapshot(context.Background())
+	if !errors.Is(err, goksei.ErrServerUnavailable) {
+		t.Errorf("error = %v, want %v", err, goksei.ErrServerUnavailable)
+	}
+
+	// nothing to reconcile without the bonds
+	if errors.Is(err, goksei.ErrNotReconciled) {
+		t.Errorf("error = %v, want no reconciliation error", err)
+	}
+
+	if snapshot.Complete() || snapshot.Errors[goksei.BondType] == nil {
+		t.Errorf("snapshot errors = %v, want bond error", snapshot.Errors)
+	}
+
+	if snapshot.Summary == nil || snapshot.HoldingTotals[goksei.EquityType] != 5_200_000 {
+		t.Errorf("snapshot = %+v, want the parts that succeeded", snapshot)
+	}
+}
+
+func TestSnapshot_Reconcile_foreignHoldings(t *testing.T) {
+	newSnapshot := func(summaryTotal float64) *goksei.Snapshot {
+		snapshot := &goksei.Snapshot{
+			Summary: &goksei.PortfolioSummaryResponse{Total: summaryTotal},
+			Cash:    []goksei.CashBalance{{AccountNumber: "123", Currency: "IDR", Balance: 1_000, BalanceIDR: 1_000}},
+			Holdings: map[goksei.PortfolioType][]goksei.ShareBalance{
+				goksei.EquityType: {
+					{Account: "XL001", FullName: "BBCA - BANK CENTRAL ASIA Tbk", Currency: "IDR", Amount: 10, ClosingPrice: 9_000},
+					{Account: "XL001", FullName: "AAPL - APPLE INC", Currency: "USD", Amount: 2, ClosingPrice: 200},
+				},
+			},
+		}
+		snapshot.ComputeTotals()
+
+		return snapshot
+	}
+
+	// KSEI reports the USD holding at its IDR value in the summary
+	snapshot := newSnapshot(1_000 + 90_000 + 2*200*16_000)
+
+	if snapshot.Total != 91_000 || snapshot.HoldingTotals[goksei.EquityType] != 90_000 {
+		t.Errorf("totals = %v, %v, want the IDR lines only", snapshot.Total, snapshot.HoldingTotals)
+	}
+
+	if got := snapshot.ForeignTotals["USD"]; got != 400 {
+		t.Errorf("ForeignTotals[USD] = %v, want 400", got)
+	}
+
+	if err := snapshot.Reconcile(); err != nil {
+		t.Errorf("Reconcile() error = %v", err)
+	}
+
+	// the IDR lines alone cannot exceed the summary
+	if err := newSnapshot(50_000).Reconcile(); !errors.Is(err, goksei.ErrNotReconciled) {
+		t.Errorf("Reconcile() error = %v, want %v", err, goksei.ErrNotReconciled)
+	}
+}
@@ -146,8 +146,31 @@ type LoginRequest struct {
 }
 
 // LoginResponse represents the response from the login API endpoint.
+// A failed login has an empty Validation and is explained by Code, Status and Message.
+// When KSEI requires an additional verification step, Validation is empty and
+// OTPRequired is set along with the details of the challenge.
+//
+// The OTP fields are assumed and not yet checked against KSEI: the challenge is modelled
+// after common login flows until a real one can be captured.
 type LoginResponse struct {
+	Code       string `json:"code"`    // e.g. "200"
+	Status     string `json:"status"`  // e.g. "success"
+	Message    string `json:"message"` // explanation of a failed login, in Indonesian
 	Validation string `json:"validation"`
+
+	OTPRequired    bool   `json:"otpRequired,omitempty"`
+	OTPToken       string `json:"otpToken,omitempty"`       // identifies the pending challenge
+	OTPMethod      string `json:"otpMethod,omitempty"`      // how the code is delivered. Example: "email", "sms" or "totp"
+	OTPDestination string `json:"otpDestination,omitempty"` // masked destination of the code. Example: "jo***@domain.com"
+}
+
+// OTPVerificationRequest represents the request payload completing a login challenge,
+// sent to POST /login/otp. Like the OTP fields of LoginResponse, the endpoint and payload
+// are assumed and not yet checked against KSEI.
+type OTPVerificationRequest struct {
+	Username string `json:"username"`
+	OTPToken string `json:"otpToken"`
+	OTP      string `json:"otp"`
 }
 
 // GlobalIdentityResponse represents the response from the global identity API endpoint.